@@ -0,0 +1,63 @@
+package httpfixture
+
+import "net/http"
+
+// Builder constructs a fixture using chained method calls, as an alternative to passing many FixtureOpts to a
+// constructor. Builders are created using New, and produce the same fixture as Bytes when Build is called.
+type Builder struct {
+	route  string
+	method string
+	status int
+	body   []byte
+	opts   []FixtureOpt
+}
+
+// New returns a Builder for a fixture hosted at the provided route. Unless otherwise configured, the fixture matches
+// any method, and responds with status 200 OK and an empty body.
+func New(route string) *Builder {
+	return &Builder{
+		route:  route,
+		method: "*",
+		status: http.StatusOK,
+	}
+}
+
+// Method sets the HTTP method matched by the fixture.
+func (b *Builder) Method(method string) *Builder {
+	b.method = method
+	return b
+}
+
+// Status sets the status code of responses from the fixture.
+func (b *Builder) Status(code int) *Builder {
+	b.status = code
+	return b
+}
+
+// Body sets the body of responses from the fixture.
+func (b *Builder) Body(body []byte) *Builder {
+	b.body = body
+	return b
+}
+
+// Header adds a header with the provided key and value to responses from the fixture. It may be called more than once
+// to add multiple values.
+func (b *Builder) Header(key, value string) *Builder {
+	return b.With(withHeader(http.Header{key: {value}}))
+}
+
+// AssertBodyContains asserts all requests passed to the fixture include a body containing the provided string.
+func (b *Builder) AssertBodyContains(str string) *Builder {
+	return b.With(AssertBodyContains(str))
+}
+
+// With applies the provided FixtureOpts to the fixture, so that any option can be used with a Builder.
+func (b *Builder) With(opts ...FixtureOpt) *Builder {
+	b.opts = append(b.opts, opts...)
+	return b
+}
+
+// Build returns the fixture configured by this Builder.
+func (b *Builder) Build() F {
+	return Bytes(b.route, b.method, b.status, b.body, b.opts...)
+}
@@ -0,0 +1,58 @@
+package httpfixture_test
+
+import (
+	"bytes"
+	"github.com/orkes-io/go-httpfixture"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	f := httpfixture.New("/widgets").
+		Method(http.MethodPost).
+		Status(http.StatusCreated).
+		Body([]byte(`{"id":1}`)).
+		Header("X-Widget", "created").
+		AssertBodyContains("gadget").
+		Build()
+
+	tests := []struct {
+		name     string
+		body     string
+		wantFail bool
+	}{
+		{name: "assertion passes", body: `{"name":"gadget"}`},
+		{name: "assertion fails", body: `{"name":"gizmo"}`, wantFail: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockT := &testing.T{}
+			s := httpfixture.NewServer(f)
+			s.Start(mockT)
+			defer s.Close()
+
+			resp, err := http.Post(s.URL()+"/widgets", "application/json", bytes.NewBufferString(tt.body))
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusCreated {
+				t.Errorf("want statusCode: %d; got: %d", http.StatusCreated, resp.StatusCode)
+			}
+			if got := resp.Header.Get("X-Widget"); got != "created" {
+				t.Errorf("want X-Widget header: %q; got: %q", "created", got)
+			}
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("error reading body: %v", err)
+			}
+			if string(body) != `{"id":1}` {
+				t.Errorf("want body: %q; got: %q", `{"id":1}`, body)
+			}
+			if mockT.Failed() != tt.wantFail {
+				t.Errorf("want failed: %t; got: %t", tt.wantFail, mockT.Failed())
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package httpfixture
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the current time to fixtures whose responses change over time. Fixtures use the system clock unless
+// another Clock is provided using WithClock.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+}
+
+// WithClock sets the clock used by a fixture whose responses change over time.
+func WithClock(c Clock) FixtureOpt {
+	return func(f *baseFixture) {
+		f.clock = c
+	}
+}
+
+// systemClock is a Clock which reports the system time.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FakeClock is a Clock whose time only changes when it is advanced or set, so that tests can control time. A FakeClock
+// is safe for concurrent use.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock creates a FakeClock set to the provided time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the current time of this clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the current time of this clock forward by the provided duration.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set sets the current time of this clock.
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
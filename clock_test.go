@@ -0,0 +1,165 @@
+package httpfixture_test
+
+import (
+	"github.com/orkes-io/go-httpfixture"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFlapping(t *testing.T) {
+	clock := httpfixture.NewFakeClock(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := httpfixture.NewServer(httpfixture.Flapping("/health", http.MethodGet,
+		httpfixture.GetOK("", "ok"),
+		httpfixture.ResponseCode("", "", http.StatusServiceUnavailable),
+		time.Minute, httpfixture.WithClock(clock)))
+	s.Start(t)
+	defer s.Close()
+
+	steps := []struct {
+		advance  time.Duration
+		wantCode int
+	}{
+		{advance: 0, wantCode: http.StatusOK},
+		{advance: 59 * time.Second, wantCode: http.StatusOK},
+		{advance: time.Second, wantCode: http.StatusServiceUnavailable},
+		{advance: 30 * time.Second, wantCode: http.StatusServiceUnavailable},
+		{advance: 30 * time.Second, wantCode: http.StatusOK},
+		{advance: 90 * time.Second, wantCode: http.StatusServiceUnavailable},
+	}
+	for i, step := range steps {
+		clock.Advance(step.advance)
+		resp, err := http.Get(s.URL() + "/health")
+		if err != nil {
+			t.Fatalf("error making request: %v", err)
+		}
+		if resp.StatusCode != step.wantCode {
+			t.Fatalf("step %d: want statusCode: %d; got: %d", i, step.wantCode, resp.StatusCode)
+		}
+	}
+}
+
+func TestFlappingAssertions(t *testing.T) {
+	mockT := &testing.T{}
+	clock := httpfixture.NewFakeClock(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := httpfixture.NewServer(httpfixture.Flapping("/health", http.MethodGet,
+		httpfixture.GetOK("", "ok"),
+		httpfixture.ResponseCode("", "", http.StatusServiceUnavailable),
+		time.Minute, httpfixture.WithClock(clock), httpfixture.AssertHeaderMatches("X-Key", "yes")))
+	s.Start(mockT)
+	defer s.Close()
+
+	if _, err := http.Get(s.URL() + "/health"); err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	if !mockT.Failed() {
+		t.Fatalf("want request without X-Key header to fail")
+	}
+}
+
+func TestFlappingInvalidPeriod(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatalf("want panic for zero period")
+		}
+	}()
+	httpfixture.Flapping("/health", http.MethodGet, httpfixture.GetOK("", "ok"), httpfixture.GetOK("", "ok"), 0)
+}
+
+func TestDegradeAfter(t *testing.T) {
+	clock := httpfixture.NewFakeClock(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := httpfixture.NewServer(httpfixture.DegradeAfter("/api", http.MethodGet, time.Hour,
+		httpfixture.GetOK("", "ok"),
+		httpfixture.ResponseCode("", "", http.StatusInternalServerError),
+		httpfixture.WithClock(clock)))
+	s.Start(t)
+	defer s.Close()
+
+	steps := []struct {
+		advance  time.Duration
+		wantCode int
+	}{
+		{advance: 0, wantCode: http.StatusOK},
+		{advance: 59 * time.Minute, wantCode: http.StatusOK},
+		{advance: time.Minute, wantCode: http.StatusInternalServerError},
+		{advance: 24 * time.Hour, wantCode: http.StatusInternalServerError},
+	}
+	for i, step := range steps {
+		clock.Advance(step.advance)
+		resp, err := http.Get(s.URL() + "/api")
+		if err != nil {
+			t.Fatalf("error making request: %v", err)
+		}
+		if resp.StatusCode != step.wantCode {
+			t.Fatalf("step %d: want statusCode: %d; got: %d", i, step.wantCode, resp.StatusCode)
+		}
+	}
+}
+
+func TestDegradeAfterAssertions(t *testing.T) {
+	mockT := &testing.T{}
+	s := httpfixture.NewServer(httpfixture.DegradeAfter("/api", http.MethodGet, time.Hour,
+		httpfixture.GetOK("", "ok"),
+		httpfixture.ResponseCode("", "", http.StatusInternalServerError),
+		httpfixture.AssertHeaderMatches("X-Key", "yes")))
+	s.Start(mockT)
+	defer s.Close()
+
+	if _, err := http.Get(s.URL() + "/api"); err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	if !mockT.Failed() {
+		t.Fatalf("want request without X-Key header to fail")
+	}
+	if failures := s.AssertionFailures(); len(failures) != 1 {
+		t.Fatalf("want 1 assertion failure; got: %v", failures)
+	}
+}
+
+func TestByTime(t *testing.T) {
+	clock := httpfixture.NewFakeClock(time.Date(2023, 1, 2, 12, 0, 0, 0, time.UTC)) // a Monday.
+	s := httpfixture.NewServer(httpfixture.ByTime("/hours", http.MethodGet, func(now time.Time) httpfixture.F {
+		if wd := now.Weekday(); wd == time.Saturday || wd == time.Sunday {
+			return httpfixture.GetOK("", "closed")
+		}
+		return httpfixture.GetOK("", "open")
+	}, httpfixture.WithClock(clock)))
+	s.Start(t)
+	defer s.Close()
+
+	steps := []struct {
+		advance  time.Duration
+		wantBody string
+	}{
+		{advance: 0, wantBody: "open"},
+		{advance: 5 * 24 * time.Hour, wantBody: "closed"},
+		{advance: 2 * 24 * time.Hour, wantBody: "open"},
+	}
+	for i, step := range steps {
+		clock.Advance(step.advance)
+		resp, err := http.Get(s.URL() + "/hours")
+		if err != nil {
+			t.Fatalf("error making request: %v", err)
+		}
+		if body := string(must(io.ReadAll(resp.Body))); body != step.wantBody {
+			t.Fatalf("step %d: want body: '%s'; got: '%s'", i, step.wantBody, body)
+		}
+	}
+}
+
+func TestByTimeAssertions(t *testing.T) {
+	mockT := &testing.T{}
+	s := httpfixture.NewServer(httpfixture.ByTime("/hours", http.MethodGet, func(now time.Time) httpfixture.F {
+		return httpfixture.GetOK("", "open")
+	}, httpfixture.AssertHeaderMatches("X-Key", "yes")))
+	s.Start(mockT)
+	defer s.Close()
+
+	if _, err := http.Get(s.URL() + "/hours"); err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	if !mockT.Failed() {
+		t.Fatalf("want request without X-Key header to fail")
+	}
+}
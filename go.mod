@@ -1,3 +1,3 @@
 module github.com/orkes-io/go-httpfixture
 
-go 1.18
+go 1.20
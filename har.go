@@ -0,0 +1,110 @@
+package httpfixture
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// FromHAR parses an HTTP Archive (HAR) 1.2 document, such as those exported by browser developer tools, returning
+// fixtures which replay its entries. As with NewRecordReplayServer in replay mode, entries are matched by method and
+// path, and entries which share a method and path are replayed in order, as in Seq.
+//
+// Fixtures respond with the recorded status, headers, and body of each entry. Bodies with base64 encoding are decoded,
+// and gzip-compressed bodies are decompressed, so bodies are always served without a Content-Encoding. Content-Length,
+// hop-by-hop headers, and HTTP/2 pseudo-headers are also omitted from responses.
+func FromHAR(r io.Reader) ([]F, error) {
+	var doc harDocument
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("error parsing HAR: %w", err)
+	}
+	var rec recording
+	for i, e := range doc.Log.Entries {
+		entry, err := e.recordedEntry()
+		if err != nil {
+			return nil, fmt.Errorf("error in HAR entry %d: %w", i, err)
+		}
+		rec.Entries = append(rec.Entries, entry)
+	}
+	return rec.fixtures(), nil
+}
+
+// harDocument is the subset of the HAR 1.2 format used by FromHAR.
+type harDocument struct {
+	Log struct {
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	Request struct {
+		Method string `json:"method"`
+		URL    string `json:"url"`
+	} `json:"request"`
+	Response struct {
+		Status  int `json:"status"`
+		Headers []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"headers"`
+		Content struct {
+			Text     string `json:"text"`
+			Encoding string `json:"encoding"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+// omittedHARHeaders are response headers which no longer apply to the decoded body served by HAR fixtures.
+var omittedHARHeaders = []string{"Content-Encoding", "Content-Length"}
+
+func (e harEntry) recordedEntry() (recordedEntry, error) {
+	u, err := url.Parse(e.Request.URL)
+	if err != nil {
+		return recordedEntry{}, fmt.Errorf("error parsing request URL: %w", err)
+	}
+	body := []byte(e.Response.Content.Text)
+	if e.Response.Content.Encoding == "base64" {
+		body, err = base64.StdEncoding.DecodeString(e.Response.Content.Text)
+		if err != nil {
+			return recordedEntry{}, fmt.Errorf("error decoding base64 response body: %w", err)
+		}
+	}
+	header := make(http.Header)
+	for _, h := range e.Response.Headers {
+		if strings.HasPrefix(h.Name, ":") {
+			continue
+		}
+		header.Add(h.Name, h.Value)
+	}
+	if strings.Contains(strings.ToLower(header.Get("Content-Encoding")), "gzip") && isGzip(body) {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return recordedEntry{}, fmt.Errorf("error reading gzip response body: %w", err)
+		}
+		if body, err = io.ReadAll(zr); err != nil {
+			return recordedEntry{}, fmt.Errorf("error decompressing response body: %w", err)
+		}
+	}
+	for _, h := range append(hopHeaders, omittedHARHeaders...) {
+		header.Del(h)
+	}
+	return recordedEntry{
+		Method: strings.ToUpper(e.Request.Method),
+		Path:   u.Path,
+		Query:  u.RawQuery,
+		Status: e.Response.Status,
+		Header: header,
+		Body:   body,
+	}, nil
+}
+
+// isGzip returns true if the provided bytes begin with the gzip magic number.
+func isGzip(b []byte) bool {
+	return len(b) >= 2 && b[0] == 0x1f && b[1] == 0x8b
+}
@@ -0,0 +1,96 @@
+package httpfixture_test
+
+import (
+	"github.com/orkes-io/go-httpfixture"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFromHAR(t *testing.T) {
+	f, err := os.Open("testdata/example.har")
+	if err != nil {
+		t.Fatalf("error opening HAR: %v", err)
+	}
+	defer f.Close()
+	fixtures, err := httpfixture.FromHAR(f)
+	if err != nil {
+		t.Fatalf("error parsing HAR: %v", err)
+	}
+	s := httpfixture.NewServer(fixtures...)
+	s.Start(t)
+	defer s.Close()
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		wantCode    int
+		wantType    string
+		wantBody    string
+		wantHeaders map[string]string
+	}{
+		{
+			name:     "gzip base64 body",
+			method:   http.MethodGet,
+			path:     "/api/users?page=1",
+			wantCode: http.StatusOK,
+			wantType: "application/json",
+			wantBody: `{"users":[{"id":1,"name":"gopher"}]}`,
+		},
+		{
+			name:        "text body",
+			method:      http.MethodPost,
+			path:        "/api/users",
+			wantCode:    http.StatusCreated,
+			wantType:    "application/json",
+			wantBody:    `{"id":2}`,
+			wantHeaders: map[string]string{"Location": "/api/users/2"},
+		},
+		{
+			name:     "repeated entry",
+			method:   http.MethodPost,
+			path:     "/api/users",
+			wantCode: http.StatusConflict,
+			wantType: "application/json",
+			wantBody: `{"error":"duplicate"}`,
+		},
+		{
+			name:     "binary body",
+			method:   http.MethodGet,
+			path:     "/static/logo.png",
+			wantCode: http.StatusOK,
+			wantType: "image/png",
+			wantBody: "\x89PNG\r\n\x1a\nfake",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := must(http.NewRequest(tt.method, s.URL()+tt.path, strings.NewReader("")))
+			req.Header.Set("Accept-Encoding", "identity")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("want statusCode: %d; got: %d", tt.wantCode, resp.StatusCode)
+			}
+			if ct := resp.Header.Get("Content-Type"); ct != tt.wantType {
+				t.Fatalf("want Content-Type: %s; got: %s", tt.wantType, ct)
+			}
+			if ce := resp.Header.Get("Content-Encoding"); ce != "" {
+				t.Fatalf("want no Content-Encoding; got: %s", ce)
+			}
+			for key, want := range tt.wantHeaders {
+				if got := resp.Header.Get(key); got != want {
+					t.Fatalf("want %s header: %s; got: %s", key, want, got)
+				}
+			}
+			if body := string(must(io.ReadAll(resp.Body))); body != tt.wantBody {
+				t.Fatalf("want body: '%s'; got: '%s'", tt.wantBody, body)
+			}
+		})
+	}
+}
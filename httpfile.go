@@ -0,0 +1,179 @@
+package httpfixture
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ParseHTTPFile reads request definitions from the .http file at the provided path, in the format used by the
+// IntelliJ and VS Code REST clients, returning one fixture for each request found. Each fixture matches on the method
+// and path of its request, and responds with the response associated with the request, if any.
+//
+// Only a subset of the format is supported. Requests are separated by lines beginning with '###', and consist of a
+// request line, followed by headers and an optional body. Responses may be associated with a request in one of two
+// ways:
+//
+//   - a response reference line, like '<> response.json', pointing to a file containing the response body, relative to
+//     the .http file. The response status is 200 OK.
+//   - an inline response following the request, which begins with a status line like 'HTTP/1.1 201 Created', and is
+//     followed by headers and a body.
+//
+// Requests without a response respond with 200 OK and an empty body. Request headers, request bodies, response headers,
+// response handler scripts, and variables are ignored. A variable at the start of a URL, like '{{host}}/api', is
+// stripped from the route.
+func ParseHTTPFile(path string) ([]F, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading .http file: %w", err)
+	}
+	defer f.Close()
+
+	var (
+		result []F
+		req    *httpFileRequest
+		lineNo int
+	)
+	flush := func() error {
+		if req == nil {
+			return nil
+		}
+		fixture, err := req.fixture(filepath.Dir(path))
+		if err != nil {
+			return fmt.Errorf("error in request on line %d: %w", req.line, err)
+		}
+		result = append(result, fixture)
+		req = nil
+		return nil
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(line, "###") {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if req == nil {
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "//") {
+				continue
+			}
+			req, err = parseRequestLine(trimmed)
+			if err != nil {
+				return nil, fmt.Errorf("error on line %d: %w", lineNo, err)
+			}
+			req.line = lineNo
+			continue
+		}
+		if err := req.addLine(line); err != nil {
+			return nil, fmt.Errorf("error on line %d: %w", lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading .http file: %w", err)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// httpFileSection identifies which part of a request definition in a .http file is being parsed.
+type httpFileSection int
+
+const (
+	sectionReqHeaders httpFileSection = iota
+	sectionReqBody
+	sectionRespHeaders
+	sectionRespBody
+)
+
+// httpFileRequest is a single request definition parsed from a .http file.
+type httpFileRequest struct {
+	line     int
+	method   string
+	route    string
+	section  httpFileSection
+	respCode int
+	respFile string
+	respBody []string
+}
+
+func parseRequestLine(line string) (*httpFileRequest, error) {
+	fields := strings.Fields(line)
+	method, target := http.MethodGet, fields[0]
+	if len(fields) > 1 && !strings.HasPrefix(fields[1], "HTTP/") {
+		method, target = fields[0], fields[1]
+	}
+	if strings.HasPrefix(target, "{{") {
+		end := strings.Index(target, "}}")
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated variable in %s", target)
+		}
+		target = target[end+2:]
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing request URL: %w", err)
+	}
+	return &httpFileRequest{method: strings.ToUpper(method), route: u.Path}, nil
+}
+
+func (r *httpFileRequest) addLine(line string) error {
+	switch {
+	case strings.HasPrefix(line, "<>"):
+		r.respFile = strings.TrimSpace(strings.TrimPrefix(line, "<>"))
+		return nil
+	case strings.HasPrefix(line, ">"):
+		return nil // response handler scripts are not supported.
+	case r.section < sectionRespHeaders && strings.HasPrefix(line, "HTTP/"):
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return fmt.Errorf("missing status code in response line: %s", line)
+		}
+		code, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return fmt.Errorf("error parsing status code: %w", err)
+		}
+		r.respCode = code
+		r.section = sectionRespHeaders
+		return nil
+	}
+	switch r.section {
+	case sectionReqHeaders:
+		if strings.TrimSpace(line) == "" {
+			r.section = sectionReqBody
+		}
+	case sectionRespHeaders:
+		if strings.TrimSpace(line) == "" {
+			r.section = sectionRespBody
+		}
+	case sectionRespBody:
+		r.respBody = append(r.respBody, line)
+	}
+	return nil
+}
+
+func (r *httpFileRequest) fixture(dir string) (F, error) {
+	code := http.StatusOK
+	if r.respCode != 0 {
+		code = r.respCode
+	}
+	if r.respFile != "" {
+		body, err := os.ReadFile(filepath.Join(dir, r.respFile))
+		if err != nil {
+			return nil, fmt.Errorf("error reading response file: %w", err)
+		}
+		return Bytes(r.route, r.method, code, body), nil
+	}
+	body := strings.TrimRight(strings.Join(r.respBody, "\n"), "\n")
+	return Bytes(r.route, r.method, code, []byte(body)), nil
+}
@@ -0,0 +1,73 @@
+package httpfixture_test
+
+import (
+	"bytes"
+	"github.com/orkes-io/go-httpfixture"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestParseHTTPFile(t *testing.T) {
+	fixtures, err := httpfixture.ParseHTTPFile("testdata/example.http")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fixtures) != 3 {
+		t.Fatalf("want 3 fixtures; got: %d", len(fixtures))
+	}
+	s := httpfixture.NewServer(fixtures...)
+	s.Start(t)
+	defer s.Close()
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "response file",
+			method:   http.MethodGet,
+			path:     "/api/users",
+			wantCode: http.StatusOK,
+			wantBody: `{"foo":"bar","number":1}`,
+		},
+		{
+			name:     "inline response",
+			method:   http.MethodPost,
+			path:     "/api/users",
+			wantCode: http.StatusCreated,
+			wantBody: `{"id":42,"name":"gopher"}`,
+		},
+		{
+			name:     "inline response without body",
+			method:   http.MethodDelete,
+			path:     "/api/users/42",
+			wantCode: http.StatusNoContent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := must(http.NewRequest(tt.method, s.URL()+tt.path, bytes.NewBufferString(`{"name":"gopher"}`)))
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("want statusCode: %d; got: %d", tt.wantCode, resp.StatusCode)
+			}
+			body := string(must(io.ReadAll(resp.Body)))
+			if body != tt.wantBody {
+				t.Fatalf("want body: '%s'; got: '%s'", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestParseHTTPFileMissing(t *testing.T) {
+	if _, err := httpfixture.ParseHTTPFile("testdata/missing.http"); err == nil {
+		t.Fatalf("expected error reading missing file")
+	}
+}
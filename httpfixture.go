@@ -1,27 +1,59 @@
 // Package httpfixture provides HTTP fixtures for testing code that makes requests via HTTP servers. It aims to provide
 // a more convenient abstraction than httptest, resulting in tests that use less code. All fixtures provided by this
-// package are logicless: responses from the fixture are fixed and do not depend on the incoming request.
+// package are logicless by default: responses from the fixture are fixed and do not depend on the incoming request.
+// A few fixtures, such as Template, opt in to computing responses from the incoming request.
 package httpfixture
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
+	"math/rand"
+	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/http/httputil"
+	"net/textproto"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"text/template"
+	"time"
 )
 
 // F is an HTTP fixture.
 type F interface {
-	// Run runs this fixture, exchanging the provided request for a response.
-	Run(t *testing.T, req *http.Request) *http.Response
-	// Route returns the route where this Fixture is hosted.
+	// Run runs this fixture, exchanging the provided request for a response. Fixtures which take a long time to respond
+	// should observe req.Context(), and return a nil response once it is done. Nothing more is written for a nil
+	// response, so unless the fixture has written to or hijacked the connection itself, net/http sends an implicit 200
+	// OK with an empty body to any client still waiting.
+	Run(t testing.TB, req *http.Request) *http.Response
+	// Route returns the route where this Fixture is hosted. Routes match any request whose path is the route, or lies
+	// beneath it: "/users" matches "/users" and "/users/1", but not "/usersettings". When several routes match, the
+	// longest wins. Both routes and request paths are cleaned prior to matching, so trailing slashes are not significant.
 	Route() string
-	// Method returns the method which this Fixture matches on.
+	// Method returns the method which this Fixture matches on, or "*" to match any method. Methods are matched without
+	// regard to case.
 	Method() string
 }
 
@@ -33,6 +65,12 @@ func OK(route string, body string, opts ...FixtureOpt) F {
 	return BytesOK(route, "*", []byte(body), opts...)
 }
 
+// CachedOK returns a fixture which responds to any request at the provided route with the provided body and status 200
+// OK, along with a Cache-Control header containing the provided directives, such as "public, max-age=60".
+func CachedOK(route string, body string, directives string, opts ...FixtureOpt) F {
+	return OK(route, body, append([]FixtureOpt{WithCacheControl(directives)}, opts...)...)
+}
+
 // GetOK returns a fixture which responds to GET requests at the provided route with the provided response body, and
 // status 200 OK.
 func GetOK(route string, body string, opts ...FixtureOpt) F {
@@ -60,6 +98,12 @@ func Bytes(route, method string, responseCode int, body []byte, opts ...FixtureO
 	}
 }
 
+// ChunkedOK returns a fixture which responds to requests at the provided route and HTTP method with the provided body
+// and status 200 OK, using chunked transfer encoding and no Content-Length header.
+func ChunkedOK(route, method string, body []byte, opts ...FixtureOpt) F {
+	return BytesOK(route, method, body, append([]FixtureOpt{WithChunked()}, opts...)...)
+}
+
 // GetFileOK returns a fixture which responds to GET requests at the provided route with the contents of the provided
 // file and status 200 OK. The file at the provided path is read into memory by this func.
 func GetFileOK(route, path string, opts ...FixtureOpt) F {
@@ -73,14 +117,83 @@ func FileOK(route, method string, path string, opts ...FixtureOpt) F {
 }
 
 // File returns a fixture which responds to matching requests with the contents of the provided file, which are read
-// into memory by this func.
+// into memory by this func. If the file cannot be opened, this func panics with an error matching ErrFixtureFile.
+//
+// The Content-Type of responses is detected from the file's extension, or by sniffing its contents using
+// http.DetectContentType if the extension is not recognized. The detected type can be overridden using WithHeader.
 func File(route, method string, responseCode int, path string, opts ...FixtureOpt) F {
+	b, contentType := readFixtureFile(path)
+	opts = append([]FixtureOpt{WithHeader("Content-Type", contentType)}, opts...)
+	return &memFixture{
+		body:        b,
+		baseFixture: base(route, method, responseCode, opts...),
+	}
+}
+
+// GzipFileOK returns a fixture which responds to matching requests with the contents of the provided file and status
+// 200 OK, as in FileOK. Requests whose Accept-Encoding header allows gzip receive the gzip-compressed contents, along
+// with the header Content-Encoding: gzip; other requests receive the uncompressed contents. The file is read into
+// memory and compressed by this func.
+func GzipFileOK(route, method, path string, opts ...FixtureOpt) F {
+	b, contentType := readFixtureFile(path)
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write(b); err != nil {
+		panic(fmt.Errorf("error compressing fixture file: %w", err))
+	}
+	if err := zw.Close(); err != nil {
+		panic(fmt.Errorf("error compressing fixture file: %w", err))
+	}
+	opts = append([]FixtureOpt{
+		WithHeader("Content-Type", contentType),
+		withHeader(http.Header{"Vary": {"Accept-Encoding"}}),
+	}, opts...)
+	return &gzipFixture{
+		plain:       b,
+		compressed:  compressed.Bytes(),
+		baseFixture: base(route, method, http.StatusOK, opts...),
+	}
+}
+
+// readFixtureFile reads the contents of the file at the provided path, and detects its Content-Type from its extension
+// or contents. If the file cannot be read, this func panics with an error matching ErrFixtureFile.
+func readFixtureFile(path string) ([]byte, string) {
 	f, err := os.Open(path)
 	if err != nil {
-		panic(fmt.Errorf("error reading file: %w", err))
+		panic(&fixtureFileError{path: path, err: err})
 	}
 	defer f.Close()
-	return Reader(route, method, responseCode, f, opts...)
+	b, err := io.ReadAll(f)
+	if err != nil {
+		panic(&fixtureFileError{path: path, err: err})
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		contentType = http.DetectContentType(b)
+	}
+	return b, contentType
+}
+
+// ErrFixtureFile is matched, using errors.Is, by the values of panics caused by fixture files which cannot be opened.
+// The panic value also wraps the underlying error, so errors.Is(v, fs.ErrNotExist) reports whether the file is missing.
+var ErrFixtureFile = errors.New("httpfixture: error opening fixture file")
+
+// fixtureFileError is the panic value used when a fixture file cannot be opened.
+type fixtureFileError struct {
+	path string
+	err  error
+}
+
+func (e *fixtureFileError) Error() string {
+	return fmt.Sprintf("%v: %s: %v", ErrFixtureFile, e.path, e.err)
+}
+
+func (e *fixtureFileError) Is(target error) bool {
+	return target == ErrFixtureFile
+}
+
+func (e *fixtureFileError) Unwrap() error {
+	return e.err
 }
 
 // Reader returns a fixture which responds to matching requests with the contents of the provided reader, which are read
@@ -96,261 +209,2440 @@ func Reader(route, method string, responseCode int, reader io.Reader, opts ...Fi
 	}
 }
 
+// Response returns a fixture which responds to matching requests with a copy of the provided response, including its
+// status code, headers, trailers, and body. The body of resp is read into memory by this func, and replaced with a
+// reader over the same bytes, so that resp can still be read by the caller. Only the StatusCode, Header, Trailer,
+// TransferEncoding, and Body fields of resp are used.
+func Response(route, method string, resp *http.Response, opts ...FixtureOpt) F {
+	var body []byte
+	if resp.Body != nil {
+		b, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			panic(fmt.Errorf("error reading response body: %w", err))
+		}
+		body = b
+		resp.Body = io.NopCloser(bytes.NewReader(b))
+	}
+	opts = append([]FixtureOpt{withHeader(resp.Header)}, opts...)
+	for key, vals := range resp.Trailer {
+		for _, v := range vals {
+			opts = append(opts, WithTrailer(key, v))
+		}
+	}
+	if isChunked(resp) {
+		opts = append(opts, WithChunked())
+	}
+	return &memFixture{
+		body:        body,
+		baseFixture: base(route, method, resp.StatusCode, opts...),
+	}
+}
+
+// MultipartPart is a single part of a multipart response body.
+type MultipartPart struct {
+	// Header contains the headers of this part, such as its Content-Type.
+	Header http.Header
+	// Body is the body of this part.
+	Body []byte
+}
+
+// MultipartMixed returns a fixture which responds to any request at the provided route with status 200 OK and a
+// multipart/mixed body containing the provided parts, in order, as used by batch APIs. The boundary separating the parts
+// is generated randomly by this func, and included in the Content-Type header of the response.
+func MultipartMixed(route string, parts []MultipartPart, opts ...FixtureOpt) F {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for _, p := range parts {
+		pw, err := w.CreatePart(textproto.MIMEHeader(p.Header.Clone()))
+		if err != nil {
+			panic(fmt.Errorf("error creating multipart part: %w", err))
+		}
+		_, _ = pw.Write(p.Body) // writes to a bytes.Buffer cannot fail.
+	}
+	if err := w.Close(); err != nil {
+		panic(fmt.Errorf("error closing multipart body: %w", err))
+	}
+	contentType := mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": w.Boundary()})
+	opts = append([]FixtureOpt{withHeader(http.Header{"Content-Type": {contentType}})}, opts...)
+	return BytesOK(route, "*", body.Bytes(), opts...)
+}
+
+// Template returns a fixture which responds to matching requests with the provided response code and a body rendered
+// from tmpl. The template is parsed using text/template by this func, which panics if it is invalid, and is executed
+// for each request with the incoming *http.Request as its data, e.g. '{{.Header.Get "X-Correlation-Id"}}'.
+func Template(route, method string, responseCode int, tmpl string, opts ...FixtureOpt) F {
+	return &templateFixture{
+		tmpl:        template.Must(template.New(route).Parse(tmpl)),
+		baseFixture: base(route, method, responseCode, opts...),
+	}
+}
+
+// PathFunc returns a fixture which responds to matching requests with the status code and body returned by fn, which
+// is passed the path of each incoming request.
+func PathFunc(route, method string, fn func(path string) (int, []byte)) F {
+	return &funcFixture{
+		fn: func(req *http.Request) (int, []byte) {
+			return fn(req.URL.Path)
+		},
+		baseFixture: base(route, method, 0),
+	}
+}
+
+// StatusFunc returns a fixture which responds to matching requests with the provided body, and the status code returned
+// by fn, which is passed each incoming request.
+func StatusFunc(route, method string, fn func(req *http.Request) int, body string, opts ...FixtureOpt) F {
+	return &funcFixture{
+		fn: func(req *http.Request) (int, []byte) {
+			return fn(req), []byte(body)
+		},
+		baseFixture: base(route, method, 0, opts...),
+	}
+}
+
+// TraceEcho returns a fixture which responds to TRACE requests at the provided route by echoing the received request
+// back to the client, with status 200 OK and content type message/http, as described by RFC 9110.
+func TraceEcho(route string, opts ...FixtureOpt) F {
+	opts = append([]FixtureOpt{withHeader(http.Header{"Content-Type": {"message/http"}})}, opts...)
+	return &funcFixture{
+		fn: func(req *http.Request) (int, []byte) {
+			b, err := httputil.DumpRequest(req, true)
+			if err != nil {
+				return http.StatusInternalServerError, nil
+			}
+			return http.StatusOK, b
+		},
+		baseFixture: base(route, http.MethodTrace, 0, opts...),
+	}
+}
+
 // Seq returns a fixture which responds with the provided list of fixtures, each of which is returned exactly once in
 // the order they are provided, except for the last fixture, which is returned as often as this fixture is called.
 //
 // All assertions on sub-fixtures of a Seq are run. However, the routes and methods of sub-fixtures are ignored when
-// run as part of a Seq.
+// run as part of a Seq. Use SeqOpts to add assertions which are run on every request in the sequence.
 func Seq(route, method string, fixtures ...F) F {
+	return SeqOpts(route, method, fixtures)
+}
+
+// SeqOpts returns a fixture which responds with the provided list of fixtures in order, as in Seq. Assertions provided
+// in opts are run on every request, before the assertions of the sub-fixture which serves it; e.g. to assert that all
+// requests in the sequence include an auth header.
+func SeqOpts(route, method string, fixtures []F, opts ...FixtureOpt) F {
 	return &multiFixture{
 		fixtures:    fixtures,
+		baseFixture: base(route, method, 0, opts...),
+	}
+}
+
+// Progresser is implemented by fixtures returned by Seq, so tests can check how far through the sequence a client got.
+type Progresser interface {
+	// Progress reports the number of requests served so far, and the total number of fixtures in the sequence. A served
+	// count greater than total means the last fixture was repeated; a count less than total means some fixtures were
+	// never served.
+	Progress() (served, total int)
+}
+
+// ByPrefer returns a fixture which responds with one of the provided fixtures, selected by the preferences listed in
+// the Prefer header of the incoming request (e.g. "return=minimal"). Preferences are considered in the order they are
+// sent; the first one found in byPref is used. If none are found, defaultF is used.
+//
+// As with Seq, the routes and methods of sub-fixtures are ignored.
+func ByPrefer(route, method string, byPref map[string]F, defaultF F) F {
+	return &selectFixture{
+		choose: func(req *http.Request) F {
+			for _, h := range req.Header.Values("Prefer") {
+				for _, pref := range strings.Split(h, ",") {
+					pref, _, _ = strings.Cut(pref, ";")
+					if f, ok := byPref[strings.TrimSpace(pref)]; ok {
+						return f
+					}
+				}
+			}
+			return defaultF
+		},
 		baseFixture: base(route, method, 0),
 	}
 }
 
-// NotFound returns a fixture which returns 404 Not Found in response to any request, along with an empty body.
-func NotFound(route, method string, opts ...FixtureOpt) F {
-	return ResponseCode(route, method, http.StatusNotFound, opts...)
+// ByHeader returns a fixture which responds with one of the provided fixtures, selected by the value of the header key
+// in the incoming request. If the header is missing, or its value is not found in responses, defaultF is used.
+//
+// As with Seq, the routes and methods of sub-fixtures are ignored.
+func ByHeader(route, method, key string, responses map[string]F, defaultF F) F {
+	return &selectFixture{
+		choose: func(req *http.Request) F {
+			if f, ok := responses[req.Header.Get(key)]; ok {
+				return f
+			}
+			return defaultF
+		},
+		baseFixture: base(route, method, 0),
+	}
 }
 
-// ResponseCode returns a fixture which returns the provided response code in response to any request, along with an
-// empty body.
-func ResponseCode(route, method string, responseCode int, opts ...FixtureOpt) F {
-	bf := base(route, method, responseCode, opts...)
-	return &bf
+// WebhookHMAC returns a fixture which verifies that incoming requests are signed in the style of GitHub or Stripe
+// webhooks. The header sigHeader must contain prefix followed by the hex-encoded HMAC-SHA256 of the request body,
+// computed using secret. Requests with a missing or invalid signature receive 401 Unauthorized; all others are
+// delegated to inner. The returned fixture matches requests using the method of inner.
+func WebhookHMAC(route, secret, sigHeader, prefix string, inner F) F {
+	return guard(route, inner.Method(), http.StatusUnauthorized, func(req *http.Request) bool {
+		sig := req.Header.Get(sigHeader)
+		if !strings.HasPrefix(sig, prefix) {
+			return false
+		}
+		want, err := hex.DecodeString(strings.TrimPrefix(sig, prefix))
+		if err != nil {
+			return false
+		}
+		body, err := readBody(req)
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		return hmac.Equal(mac.Sum(nil), want)
+	}, inner)
 }
 
-func base(route, method string, responseCode int, opts ...FixtureOpt) baseFixture {
-	bf := baseFixture{
-		method:       method,
-		route:        standardizePath(route),
-		responseCode: responseCode,
+// RequireHeaderValue returns a fixture which delegates requests to inner only if they include the provided header key,
+// value pair. All other requests receive 401 Unauthorized.
+func RequireHeaderValue(route, method, key, value string, inner F) F {
+	return guard(route, method, http.StatusUnauthorized, func(req *http.Request) bool {
+		for _, v := range req.Header.Values(key) {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}, inner)
+}
+
+// CSRFProtected returns a fixture which delegates requests to inner only if the value of their headerKey header is the
+// provided CSRF token. Requests with a missing or mismatched token receive 403 Forbidden and an empty body.
+func CSRFProtected(route, method, headerKey, token string, inner F) F {
+	return guard(route, method, http.StatusForbidden, func(req *http.Request) bool {
+		v := req.Header.Get(headerKey)
+		return v != "" && v == token
+	}, inner)
+}
+
+// guard returns a fixture which delegates requests to inner if allow returns true, and responds with the provided
+// response code and an empty body otherwise.
+func guard(route, method string, code int, allow func(req *http.Request) bool, inner F) F {
+	denied := ResponseCode(route, method, code)
+	return &selectFixture{
+		choose: func(req *http.Request) F {
+			if allow(req) {
+				return inner
+			}
+			return denied
+		},
+		baseFixture: base(route, method, 0),
 	}
-	for _, opt := range opts {
-		opt(&bf)
+}
+
+// SlowReader returns a fixture which reads the body of incoming requests at no more than readRate bytes per second
+// before responding with the provided response code and an empty body. It models a slow server, and can be used to
+// trigger client write timeouts.
+func SlowReader(route, method string, code int, readRate int) F {
+	return &slowReaderFixture{
+		readRate:    readRate,
+		baseFixture: base(route, method, code),
 	}
-	return bf
 }
 
-// AssertURLContains asserts that the URL passed contains the provided substring.
-func AssertURLContains(substr string) FixtureOpt {
-	return func(f *baseFixture) {
-		f.assertions = append(f.assertions, func(req *http.Request) error {
-			url := req.URL.String()
-			if !strings.Contains(url, substr) {
-				return fmt.Errorf("url %s did not contain %s", url, substr)
+// EventuallyFound returns a fixture which models an eventually consistent resource. It responds with 404 Not Found
+// and an empty body to the first notReadyCount requests, and with the provided body and status 200 OK to all requests
+// after that. A negative notReadyCount is treated as 0.
+func EventuallyFound(route, method string, notReadyCount int, body string) F {
+	if notReadyCount < 0 {
+		notReadyCount = 0
+	}
+	fixtures := make([]F, 0, notReadyCount+1)
+	for i := 0; i < notReadyCount; i++ {
+		fixtures = append(fixtures, NotFound(route, method))
+	}
+	return Seq(route, method, append(fixtures, BytesOK(route, method, []byte(body)))...)
+}
+
+// Flapping returns a fixture which alternates between delegating requests to healthy and unhealthy every period,
+// starting with healthy when this func is called. The fixture uses the system clock unless another Clock is provided
+// using WithClock.
+//
+// As with Seq, the routes and methods of sub-fixtures are ignored. This func panics if period is not positive.
+func Flapping(route, method string, healthy, unhealthy F, period time.Duration, opts ...FixtureOpt) F {
+	if period <= 0 {
+		panic("httpfixture: Flapping requires a positive period")
+	}
+	bf := base(route, method, 0, opts...)
+	start := bf.now()
+	return &selectFixture{
+		choose: func(req *http.Request) F {
+			if (bf.now().Sub(start)/period)%2 == 0 {
+				return healthy
 			}
-			return nil
-		})
+			return unhealthy
+		},
+		baseFixture: bf,
 	}
 }
 
-// AssertHeaderMatches asserts that the provided key, value pair is present in the headers of any incoming request.
-func AssertHeaderMatches(key, value string) FixtureOpt {
-	return func(f *baseFixture) {
-		f.assertions = append(f.assertions, func(req *http.Request) error {
-			vals := req.Header.Values(key)
-			for _, v := range vals {
-				if strings.EqualFold(v, value) {
-					return nil
-				}
+// DegradeAfter returns a fixture which delegates requests to healthy until d has elapsed since this func was called,
+// and to degraded from then on. The fixture uses the system clock unless another Clock is provided using WithClock.
+//
+// As with Seq, the routes and methods of sub-fixtures are ignored.
+func DegradeAfter(route, method string, d time.Duration, healthy, degraded F, opts ...FixtureOpt) F {
+	bf := base(route, method, 0, opts...)
+	start := bf.now()
+	return &selectFixture{
+		choose: func(req *http.Request) F {
+			if bf.now().Sub(start) < d {
+				return healthy
 			}
-			return fmt.Errorf("could not find headers matching %s: %s", key, value)
-		})
+			return degraded
+		},
+		baseFixture: bf,
 	}
 }
 
-// AssertBodyContains asserts all requests passed to this fixture include a body containing the provided string.
-func AssertBodyContains(str string) FixtureOpt {
-	return AssertBodyContainsBytes([]byte(str))
+// ByTime returns a fixture which delegates each request to the sub-fixture returned by fn for the current time, e.g.
+// to model APIs whose responses depend on the weekday or time of day. The fixture uses the system clock unless another
+// Clock is provided using WithClock.
+//
+// As with Seq, the routes and methods of sub-fixtures are ignored.
+func ByTime(route, method string, fn func(now time.Time) F, opts ...FixtureOpt) F {
+	bf := base(route, method, 0, opts...)
+	return &selectFixture{
+		choose: func(req *http.Request) F {
+			return fn(bf.now())
+		},
+		baseFixture: bf,
+	}
 }
 
-// AssertBodyContainsBytes asserts all requests passed to this fixture contains the provided byte sequence in their
-// body.
-func AssertBodyContainsBytes(b []byte) FixtureOpt {
-	return func(f *baseFixture) {
-		f.assertions = append(f.assertions, func(req *http.Request) error {
-			body := bytes.NewBuffer(make([]byte, req.ContentLength))
-			r := io.TeeReader(req.Body, body)
-			req.Body = io.NopCloser(body)
-			bodyBytes, err := io.ReadAll(r)
-			if err != nil {
-				return fmt.Errorf("error reading request body: %w", err)
-			}
-			if !bytes.Contains(bodyBytes, b) {
-				return errors.New("body did not contain expected bytes")
-			}
-			return nil
-		})
+// Versioned returns a fixture which models a resource for optimistic-locking clients. GET requests receive the current
+// body, with the current version in the ETag header, starting from "1". PUT requests replace the body with the request
+// body, increment the version, and receive 204 No Content with the new ETag. If a PUT request includes an If-Match
+// header which matches neither "*" nor the current ETag, it receives 412 Precondition Failed and the resource is
+// unchanged. All other methods receive 405 Method Not Allowed.
+func Versioned(route string, body string, opts ...FixtureOpt) F {
+	return &versionedFixture{
+		version:     1,
+		body:        []byte(body),
+		baseFixture: base(route, "*", http.StatusOK, opts...),
 	}
 }
 
-// multiFixture serves a fixed sequence of fixtures. Each fixture is served once, except for the final fixture, which is
-// repeated forever.
-type multiFixture struct {
-	fixtures []F
-	next     int
-	baseFixture
+// Cacheable returns a fixture which models a cacheable resource for clients making conditional GET requests. Responses
+// include the provided entity tag in the ETag header, quoted if necessary, and modTime in the Last-Modified header.
+// Requests receive 304 Not Modified with an empty body if their If-None-Match header matches the entity tag, or, for
+// requests without If-None-Match, if their If-Modified-Since header is no earlier than modTime. Other requests receive
+// the provided body and status 200 OK.
+func Cacheable(route string, body string, etag string, modTime time.Time, opts ...FixtureOpt) F {
+	if !strings.HasPrefix(etag, `"`) && !strings.HasPrefix(etag, `W/"`) {
+		etag = strconv.Quote(etag)
+	}
+	opts = append([]FixtureOpt{withHeader(http.Header{
+		"Etag":          {etag},
+		"Last-Modified": {modTime.UTC().Format(http.TimeFormat)},
+	})}, opts...)
+	return &cacheableFixture{
+		body:        []byte(body),
+		etag:        etag,
+		modTime:     modTime.Truncate(time.Second), // Last-Modified has a resolution of one second.
+		baseFixture: base(route, http.MethodGet, http.StatusOK, opts...),
+	}
 }
 
-// Run exchanges the provided request for an appropriate response.
-func (mf *multiFixture) Run(t *testing.T, req *http.Request) *http.Response {
-	t.Helper()
-	if mf.next == len(mf.fixtures) {
-		return mf.fixtures[len(mf.fixtures)-1].Run(t, req)
+// Hijack returns a fixture which takes over the connection of matching requests using http.Hijacker, and passes it to
+// fn, which has full control over what is written to the connection, if anything. The connection is closed after fn
+// returns. Hijack can be used to simulate servers which respond with malformed or truncated responses, or which hang
+// indefinitely.
+//
+// Hijacking is only supported for HTTP/1.x connections. Under StartTLS, fn receives a *tls.Conn, so anything it writes
+// is encrypted; Hijack does not work with StartHTTP2.
+func Hijack(route, method string, fn func(conn net.Conn)) F {
+	return &hijackFixture{
+		fn:          fn,
+		baseFixture: base(route, method, 0),
 	}
-	curr := mf.next
-	mf.next++
-	return mf.fixtures[curr].Run(t, req)
 }
 
-// memFixture is for fixtures whose response bodies fit in memory.
-type memFixture struct {
-	body []byte
-	baseFixture
+// ResetConnection returns a fixture which abruptly closes the connection of matching requests without writing a
+// response. On TCP connections, the connection is reset. See Hijack for limitations.
+func ResetConnection(route, method string) F {
+	return Hijack(route, method, func(conn net.Conn) {
+		if tlsConn, ok := conn.(*tls.Conn); ok {
+			conn = tlsConn.NetConn()
+		}
+		if tcpConn, ok := conn.(*net.TCPConn); ok {
+			_ = tcpConn.SetLinger(0)
+		}
+	})
 }
 
-// Run exchanges the provided request for an appropriate response.
-func (s *memFixture) Run(t *testing.T, req *http.Request) *http.Response {
-	t.Helper()
-	s.baseFixture.assertAll(t, req)
-	resp := s.baseFixture.response()
-	resp.Body = io.NopCloser(bytes.NewBuffer(s.body))
-	return resp
+// NotFound returns a fixture which returns 404 Not Found in response to any request, along with an empty body.
+func NotFound(route, method string, opts ...FixtureOpt) F {
+	return ResponseCode(route, method, http.StatusNotFound, opts...)
 }
 
-type baseFixture struct {
-	route        string
-	method       string
-	responseCode int
-	assertions   []assert
+// LegalBlock returns a fixture which returns 451 Unavailable For Legal Reasons in response to any request, along with
+// an empty body and a Link header identifying the entity implementing the block, blockedBy, as per RFC 7725.
+func LegalBlock(route, method, blockedBy string, opts ...FixtureOpt) F {
+	link := fmt.Sprintf(`<%s>; rel="blocked-by"`, blockedBy)
+	return ResponseCode(route, method, http.StatusUnavailableForLegalReasons,
+		append([]FixtureOpt{withHeader(http.Header{"Link": {link}})}, opts...)...)
 }
 
-func (bf *baseFixture) Run(t *testing.T, req *http.Request) *http.Response {
-	t.Helper()
-	bf.assertAll(t, req)
-	return bf.response()
+// GatewayTimeout returns a fixture which models a gateway whose upstream timed out. It waits for the provided duration
+// before responding with 504 Gateway Timeout and a short plain-text body. If the request is canceled by the client
+// before the duration elapses, no response is written.
+func GatewayTimeout(route, method string, after time.Duration, opts ...FixtureOpt) F {
+	opts = append([]FixtureOpt{WithHeader("Content-Type", "text/plain; charset=utf-8"), WithDelay(after)}, opts...)
+	return Bytes(route, method, http.StatusGatewayTimeout, []byte("upstream request timed out"), opts...)
 }
 
-// assertAll runs all request assertions against the provided incoming request. It fails and halts the current test if
-// any assertion fails.
-func (bf *baseFixture) assertAll(t *testing.T, req *http.Request) {
-	t.Helper()
-	var failedAssert bool
-	for _, a := range bf.assertions {
-		if err := a(req); err != nil {
-			t.Logf("request failed assertion: %v", err)
-			failedAssert = true
-		}
+// JSONError returns a fixture which responds to requests with the provided status code and a JSON error body, along
+// with the Content-Type application/json. The body is an object with the message in the "error" field and the status
+// code in the "code" field, e.g. {"error":"not found","code":404}; the field names can be changed using
+// WithErrorFields.
+func JSONError(route, method string, status int, message string, opts ...FixtureOpt) F {
+	opts = append([]FixtureOpt{withHeader(http.Header{"Content-Type": {"application/json"}})}, opts...)
+	bf := base(route, method, status, opts...)
+	fields := jsonErrorFields{message: "error", code: "code"}
+	if bf.errorFields != nil {
+		fields = *bf.errorFields
 	}
-	if failedAssert {
-		t.Fail()
+	bf.errorFields = nil
+	body, _ := json.Marshal(map[string]any{fields.message: message, fields.code: status}) // a string and an int always encode.
+	return &memFixture{
+		body:        body,
+		baseFixture: bf,
 	}
 }
 
-// Response creates a new response populated with fields set in this baseFixture.
-func (bf *baseFixture) response() *http.Response {
-	return &http.Response{
-		StatusCode: bf.responseCode,
+// WithErrorFields sets the names of the fields holding the message and the status code in the body of a JSONError
+// fixture. It only applies to JSONError, and has no effect on other fixtures.
+func WithErrorFields(messageField, codeField string) FixtureOpt {
+	return func(f *baseFixture) {
+		f.errorFields = &jsonErrorFields{message: messageField, code: codeField}
 	}
 }
 
-// Route returns the route used to trigger this fixture.
-func (bf *baseFixture) Route() string {
-	return bf.route
+// jsonErrorFields holds the names of the fields in the body of a JSONError fixture.
+type jsonErrorFields struct {
+	message string
+	code    string
 }
 
-// Method returns the HTTP method used to trigger this fixture.
-func (bf *baseFixture) Method() string {
-	return bf.method
+// ResponseCode returns a fixture which returns the provided response code in response to any request, along with an
+// empty body.
+func ResponseCode(route, method string, responseCode int, opts ...FixtureOpt) F {
+	bf := base(route, method, responseCode, opts...)
+	return &bf
 }
 
-type Server struct {
-	*httptest.Server
-	t      *testing.T
-	routes []F
+func base(route, method string, responseCode int, opts ...FixtureOpt) baseFixture {
+	bf := baseFixture{
+		method:       strings.ToUpper(method), // methods are case-sensitive, but clients and servers use uppercase.
+		route:        standardizePath(route),
+		responseCode: responseCode,
+	}
+	for _, opt := range opts {
+		opt(&bf)
+	}
+	return bf
 }
 
-// NewServer creates a new httpfixture.Server which responds to requests with the provided fixtures.
-func NewServer(fixtures ...F) *Server {
-	var result Server
-	result.Server = httptest.NewUnstartedServer(&result)
-	for _, f := range fixtures {
-		result.routes = append(result.routes, f)
+// WithDelay delays responses from a fixture by the provided duration. If the request is canceled by the client before
+// the delay elapses, no response is written.
+func WithDelay(d time.Duration) FixtureOpt {
+	return func(f *baseFixture) {
+		f.delay = d
 	}
-	return &result
 }
 
-// Start starts the server, reporting assertions using the provided testing.T.
-func (s *Server) Start(t *testing.T) {
-	s.t = t
-	s.Server.Start()
+// WithBandwidthLimit limits the rate at which response bodies from a fixture are written to the client to roughly
+// bytesPerSec bytes per second. The body is flushed to the client as it is written, so responses without a
+// Content-Length header are sent using chunked transfer encoding.
+func WithBandwidthLimit(bytesPerSec int) FixtureOpt {
+	return func(f *baseFixture) {
+		f.bandwidth = bytesPerSec
+	}
 }
 
-// StartTLS starts the server in TLS mode, reporting assertions using the provided testing.T.
-func (s *Server) StartTLS(t *testing.T) {
-	s.t = t
-	s.Server.StartTLS()
+// WithInformational writes an informational (1xx) response with the provided status and headers before each final
+// response from a fixture, e.g. 103 Early Hints. It may be used more than once to write several informational
+// responses, which are written in order. Informational responses are only written by fixtures served by a Server;
+// writing them relies on support for 1xx responses in net/http, added in Go 1.19.
+func WithInformational(status int, header http.Header) FixtureOpt {
+	if status < 100 || status > 199 || status == http.StatusSwitchingProtocols {
+		panic(fmt.Sprintf("httpfixture: invalid informational status %d", status))
+	}
+	return func(f *baseFixture) {
+		f.interim = append(f.interim, interimResponse{status: status, header: header.Clone()})
+	}
 }
 
-// Close closes the underlying httptest.Server.
-func (s *Server) Close() {
-	s.Server.Close()
+// WithEarlyHints writes a 103 Early Hints response with the provided headers, typically Link headers, before each
+// final response from a fixture. See WithInformational.
+func WithEarlyHints(header http.Header) FixtureOpt {
+	return WithInformational(http.StatusEarlyHints, header)
 }
 
-// URL retrieves the URL of this server, once it's been started.
-func (s *Server) URL() string {
-	return s.Server.URL
+// WithRangeSupport makes a Bytes or File fixture honor the Range header of GET requests. Requests for a single
+// satisfiable byte range, such as "bytes=0-9", receive 206 Partial Content with the requested part of the body and a
+// Content-Range header; requests for a range beyond the end of the body receive 416 Range Not Satisfiable. Responses
+// include the header Accept-Ranges: bytes. Requests for multiple ranges receive the full body.
+func WithRangeSupport() FixtureOpt {
+	return func(f *baseFixture) {
+		f.ranges = true
+	}
 }
 
-type assert func(req *http.Request) error
-
-// ServeHTTP implements the http.Handler interface.
-func (s *Server) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	if req.URL == nil {
-		s.t.Logf("nil request URL")
-		s.t.Fail()
-		return
+// WithChunked forces responses from a fixture to be sent using chunked transfer encoding. No Content-Length header is
+// sent, and the response body is flushed to the client in multiple writes.
+func WithChunked() FixtureOpt {
+	return func(f *baseFixture) {
+		f.chunked = true
 	}
-	var f F
-	for _, fixture := range s.routes {
-		m := fixture.Method()
-		if strings.HasPrefix(req.URL.Path, fixture.Route()) && (m == "*" || m == req.Method) {
-			f = fixture
-			break
+}
+
+// WithHeader sets the header key to the provided value on responses from a fixture, replacing any values set earlier,
+// such as a Content-Type detected by File.
+func WithHeader(key, value string) FixtureOpt {
+	return func(f *baseFixture) {
+		if f.header == nil {
+			f.header = make(http.Header)
 		}
+		f.header.Set(key, value)
 	}
+}
+
+// WithCacheControl sets the Cache-Control header of responses from a fixture to the provided directives, such as
+// "no-store" or "private, max-age=600".
+func WithCacheControl(directives string) FixtureOpt {
+	return WithHeader("Cache-Control", directives)
+}
+
+// WithSetCookie adds a Set-Cookie header containing the provided cookie to responses from a fixture. It may be used
+// more than once to set multiple cookies.
+func WithSetCookie(cookie *http.Cookie) FixtureOpt {
+	return withHeader(http.Header{"Set-Cookie": {cookie.String()}})
+}
+
+// MatchQuery restricts a fixture to only match requests whose query parameter key has the provided value. When more
+// than one fixture with the same route matches a request, fixtures with more Match constraints are preferred over
+// those with fewer. Route length takes precedence over constraints: a fixture with a longer matching route is preferred
+// however many constraints the others have.
+func MatchQuery(key, value string) FixtureOpt {
+	return func(f *baseFixture) {
+		f.matchers = append(f.matchers, func(req *http.Request) bool {
+			return req.URL.Query().Get(key) == value
+		})
+	}
+}
+
+// MatchHeaderAbsent restricts a fixture to only match requests which do not include the header key, e.g. to serve
+// unauthenticated requests differently from authenticated ones. It counts as a Match constraint, as in MatchQuery.
+func MatchHeaderAbsent(key string) FixtureOpt {
+	return func(f *baseFixture) {
+		f.matchers = append(f.matchers, func(req *http.Request) bool {
+			return len(req.Header.Values(key)) == 0
+		})
+	}
+}
+
+// MatchHost restricts a fixture to only match requests whose Host header matches the provided host, ignoring case. If
+// host does not include a port, the port of the request's Host is ignored. Host matching is applied in addition to
+// route and method matching: a fixture using MatchHost matches only requests for the provided host whose path matches
+// the fixture's route. As with MatchQuery, a longer matching route is preferred over a host constraint, so a fixture
+// for "/api" using MatchHost does not serve requests for "/api/users" if an unconstrained "/api/users" fixture exists.
+func MatchHost(host string) FixtureOpt {
+	return func(f *baseFixture) {
+		f.matchers = append(f.matchers, func(req *http.Request) bool {
+			reqHost := req.Host
+			if _, _, err := net.SplitHostPort(host); err != nil {
+				if h, _, err := net.SplitHostPort(reqHost); err == nil {
+					reqHost = h
+				}
+			}
+			return strings.EqualFold(reqHost, host)
+		})
+	}
+}
+
+// AssertMethod asserts that all requests passed to this fixture use the provided HTTP method, ignoring case as in
+// route matching. It is useful alongside fixtures which match any method, such as OK.
+func AssertMethod(method string) FixtureOpt {
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			if !strings.EqualFold(req.Method, method) {
+				return fmt.Errorf("request used method %s; want: %s", req.Method, method)
+			}
+			return nil
+		})
+	}
+}
+
+// WithTrailer adds an HTTP trailer with the provided key and value to responses from a fixture. The trailer is
+// declared in the Trailer header and sent after the response body, which requires the response to use chunked transfer
+// encoding.
+func WithTrailer(key, value string) FixtureOpt {
+	return func(f *baseFixture) {
+		if f.trailer == nil {
+			f.trailer = make(http.Header)
+		}
+		f.trailer.Add(key, value)
+	}
+}
+
+// MaxCalls asserts that a fixture is called no more than n times, e.g. to catch clients which retry excessively. Every
+// call after the nth fails the test. The count of calls is safe for concurrent use.
+func MaxCalls(n int) FixtureOpt {
+	return func(f *baseFixture) {
+		var calls int64
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			if c := atomic.AddInt64(&calls, 1); c > int64(n) {
+				return fmt.Errorf("fixture was called %d times; want at most %d", c, n)
+			}
+			return nil
+		})
+	}
+}
+
+// AssertURLContains asserts that the URL passed contains the provided substring.
+func AssertURLContains(substr string) FixtureOpt {
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			url := req.URL.String()
+			if !strings.Contains(url, substr) {
+				return fmt.Errorf("url %s did not contain %s", url, substr)
+			}
+			return nil
+		})
+	}
+}
+
+// AssertQueryParamPresent asserts that the provided key is present in the URL query of any incoming request, with or
+// without a value, e.g. "?debug", "?debug=", or "?debug=true".
+func AssertQueryParamPresent(key string) FixtureOpt {
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			if _, ok := req.URL.Query()[key]; !ok {
+				return fmt.Errorf("query %s did not contain parameter %s", req.URL.RawQuery, key)
+			}
+			return nil
+		})
+	}
+}
+
+// AssertQueryOrder asserts that the provided keys appear in the raw URL query of all requests passed to this fixture,
+// in the order provided. Other parameters may appear before, between, or after them. Only the first occurrence of each
+// parameter is considered.
+func AssertQueryOrder(keys ...string) FixtureOpt {
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			var (
+				next int
+				seen = make(map[string]bool)
+			)
+			for _, param := range strings.Split(req.URL.RawQuery, "&") {
+				key := param
+				if i := strings.IndexByte(param, '='); i >= 0 {
+					key = param[:i]
+				}
+				if k, err := url.QueryUnescape(key); err == nil {
+					key = k
+				}
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				if next < len(keys) && key == keys[next] {
+					next++
+				}
+			}
+			if next < len(keys) {
+				return fmt.Errorf("query %s did not contain parameters in order %v", req.URL.RawQuery, keys)
+			}
+			return nil
+		})
+	}
+}
+
+// AssertHeaderMatches asserts that the provided key, value pair is present in the headers of any incoming request.
+func AssertHeaderMatches(key, value string) FixtureOpt {
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			vals := req.Header.Values(key)
+			for _, v := range vals {
+				if strings.EqualFold(v, value) {
+					return nil
+				}
+			}
+			return fmt.Errorf("could not find headers matching %s: %s", key, value)
+		})
+	}
+}
+
+// AssertHeaderAbsent asserts that no values for the provided header key are present in any incoming request.
+func AssertHeaderAbsent(key string) FixtureOpt {
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			if vals := req.Header.Values(key); len(vals) > 0 {
+				return fmt.Errorf("expected header %s to be absent; found: %v", key, vals)
+			}
+			return nil
+		})
+	}
+}
+
+// AssertHeaderCount asserts that all requests passed to this fixture include exactly n values for the header key,
+// counting each occurrence of a repeated header separately.
+func AssertHeaderCount(key string, n int) FixtureOpt {
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			if vals := req.Header.Values(key); len(vals) != n {
+				return fmt.Errorf("expected %d values for header %s; found %d: %v", n, key, len(vals), vals)
+			}
+			return nil
+		})
+	}
+}
+
+// AssertExpect asserts that all requests passed to this fixture include an Expect header equal to the provided value,
+// ignoring case, such as "100-continue". Note that net/http servers reject requests with any other Expect value, with
+// 417 Expectation Failed, before they reach a fixture.
+func AssertExpect(value string) FixtureOpt {
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			got := req.Header.Get("Expect")
+			if got == "" {
+				return fmt.Errorf("expected Expect header %s; header was absent", value)
+			}
+			if !strings.EqualFold(got, value) {
+				return fmt.Errorf("expected Expect header %s; found: %s", value, got)
+			}
+			return nil
+		})
+	}
+}
+
+// AssertHeaderMatchesRegex asserts that at least one value of the provided header key matches the provided regular
+// expression. The pattern is compiled by this func, which panics if it is invalid.
+func AssertHeaderMatchesRegex(key, pattern string) FixtureOpt {
+	re := regexp.MustCompile(pattern)
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			vals := req.Header.Values(key)
+			for _, v := range vals {
+				if re.MatchString(v) {
+					return nil
+				}
+			}
+			return fmt.Errorf("could not find headers %s matching pattern %s; found: %v", key, pattern, vals)
+		})
+	}
+}
+
+// uuidPattern matches UUIDs in their canonical textual form, as per RFC 4122.
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// AssertIdempotencyKeyIsUUID asserts that all requests passed to this fixture include the header headerKey, such as
+// "Idempotency-Key", and that its value is a UUID in canonical form, e.g. "123e4567-e89b-12d3-a456-426614174000".
+func AssertIdempotencyKeyIsUUID(headerKey string) FixtureOpt {
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			vals := req.Header.Values(headerKey)
+			if len(vals) == 0 {
+				return fmt.Errorf("expected header %s to be present", headerKey)
+			}
+			if len(vals) > 1 || !uuidPattern.MatchString(vals[0]) {
+				return fmt.Errorf("expected header %s to be a single UUID; found: %v", headerKey, vals)
+			}
+			return nil
+		})
+	}
+}
+
+// AssertDateHeaderWithin asserts that the Date header of any incoming request is present, and is within the provided
+// duration of the current time.
+func AssertDateHeaderWithin(d time.Duration) FixtureOpt {
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			val := req.Header.Get("Date")
+			if val == "" {
+				return errors.New("missing Date header")
+			}
+			date, err := http.ParseTime(val)
+			if err != nil {
+				return fmt.Errorf("error parsing Date header: %w", err)
+			}
+			diff := time.Since(date)
+			if diff < 0 {
+				diff = -diff
+			}
+			if diff > d {
+				return fmt.Errorf("date %s was not within %s of now", val, d)
+			}
+			return nil
+		})
+	}
+}
+
+// AssertCookie asserts that all requests passed to this fixture include a cookie with the provided name and value.
+func AssertCookie(name, value string) FixtureOpt {
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			c, err := req.Cookie(name)
+			if err != nil {
+				return fmt.Errorf("could not find cookie %s", name)
+			}
+			if c.Value != value {
+				return fmt.Errorf("cookie %s had value %s; want: %s", name, c.Value, value)
+			}
+			return nil
+		})
+	}
+}
+
+// AssertContentLength asserts that all requests passed to this fixture declare a Content-Length of n bytes. Requests
+// sent using chunked transfer encoding have an unknown length, and fail this assertion.
+func AssertContentLength(n int64) FixtureOpt {
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			if req.ContentLength != n {
+				return fmt.Errorf("expected Content-Length %d; found: %d", n, req.ContentLength)
+			}
+			return nil
+		})
+	}
+}
+
+// AssertChunked asserts that all requests passed to this fixture send their body using chunked transfer encoding.
+func AssertChunked() FixtureOpt {
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			for _, te := range req.TransferEncoding {
+				if te == "chunked" {
+					return nil
+				}
+			}
+			return fmt.Errorf("expected chunked transfer encoding; found: %v", req.TransferEncoding)
+		})
+	}
+}
+
+// AssertTLSVersionAtLeast asserts that all requests passed to this fixture arrive over a TLS connection which
+// negotiated at least the provided version, such as tls.VersionTLS13. Requests which did not use TLS fail the
+// assertion.
+func AssertTLSVersionAtLeast(version uint16) FixtureOpt {
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			if req.TLS == nil {
+				return fmt.Errorf("expected a TLS connection with version at least %s", tlsVersionName(version))
+			}
+			if req.TLS.Version < version {
+				return fmt.Errorf("TLS version was %s; want at least %s", tlsVersionName(req.TLS.Version),
+					tlsVersionName(version))
+			}
+			return nil
+		})
+	}
+}
+
+// tlsVersionName returns a readable name for the provided TLS version.
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+	return fmt.Sprintf("0x%04X", version)
+}
+
+// AssertBodyContains asserts all requests passed to this fixture include a body containing the provided string.
+func AssertBodyContains(str string) FixtureOpt {
+	return AssertBodyContainsBytes([]byte(str))
+}
+
+// AssertBodyContainsBytes asserts all requests passed to this fixture contains the provided byte sequence in their
+// body.
+func AssertBodyContainsBytes(b []byte) FixtureOpt {
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			bodyBytes, err := readBody(req)
+			if err != nil {
+				return fmt.Errorf("error reading request body: %w", err)
+			}
+			if len(bodyBytes) == 0 && len(b) > 0 {
+				return fmt.Errorf("%w; expected body to contain %q", errEmptyBody, b)
+			}
+			if !bytes.Contains(bodyBytes, b) {
+				return errors.New("body did not contain expected bytes")
+			}
+			return nil
+		})
+	}
+}
+
+// errEmptyBody is returned by assertions which require a request body, when the request has none.
+var errEmptyBody = errors.New("request body was empty")
+
+// readBody reads the body of the provided request, replacing it with a bufferedBody so it can be read again. If the
+// body is already buffered, its bytes are returned without being read or copied, so the body of a request is only
+// buffered once however many assertions read it. A nil body, or http.NoBody, is read as an empty body. Callers must
+// not modify the returned bytes.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if bb, ok := req.Body.(*bufferedBody); ok {
+		bb.Reset(bb.b)
+		return bb.b, nil
+	}
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = newBufferedBody(b)
+	return b, nil
+}
+
+// rewindBody resets the body of the provided request to its start, if it has been buffered by readBody.
+func rewindBody(req *http.Request) {
+	if bb, ok := req.Body.(*bufferedBody); ok {
+		bb.Reset(bb.b)
+	}
+}
+
+// bufferedBody is a request body held in memory, which can be rewound to be read again.
+type bufferedBody struct {
+	bytes.Reader
+	b []byte
+}
+
+func newBufferedBody(b []byte) *bufferedBody {
+	bb := &bufferedBody{b: b}
+	bb.Reset(b)
+	return bb
+}
+
+// Close does nothing, as the underlying body was fully read when it was buffered.
+func (bb *bufferedBody) Close() error {
+	return nil
+}
+
+// AssertBodyMatchesRegex asserts that the full body of all requests passed to this fixture matches the provided
+// regular expression. The pattern is compiled by this func, which panics if it is invalid.
+func AssertBodyMatchesRegex(pattern string) FixtureOpt {
+	re := regexp.MustCompile(pattern)
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			body, err := readBody(req)
+			if err != nil {
+				return fmt.Errorf("error reading request body: %w", err)
+			}
+			if !re.Match(body) {
+				return fmt.Errorf("body did not match pattern %s", pattern)
+			}
+			return nil
+		})
+	}
+}
+
+// AssertBodyStreaming asserts that fn returns no error when passed a reader over the body of requests passed to this
+// fixture. The bytes read by fn are retained, so that the full body is still available to other assertions and
+// fixtures, but fn need not read the entire body.
+func AssertBodyStreaming(fn func(r io.Reader) error) FixtureOpt {
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			body := req.Body
+			if body == nil {
+				body = http.NoBody
+			}
+			var read bytes.Buffer
+			err := fn(io.TeeReader(body, &read))
+			req.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(&read, body), body}
+			if err != nil {
+				return fmt.Errorf("streaming body assertion failed: %w", err)
+			}
+			return nil
+		})
+	}
+}
+
+// AssertJSONPath asserts that all requests passed to this fixture have a JSON body with the expected value at the
+// provided path. Paths are made of object keys and array indices separated by dots, e.g. "user.address.city" or
+// "items.0.id". String values are compared to expected directly; all other values are compared using their JSON
+// encoding, e.g. "42", "true", or "null".
+func AssertJSONPath(path, expected string) FixtureOpt {
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			body, err := readBody(req)
+			if err != nil {
+				return fmt.Errorf("error reading request body: %w", err)
+			}
+			if len(body) == 0 {
+				return fmt.Errorf("%w; expected a json body", errEmptyBody)
+			}
+			dec := json.NewDecoder(bytes.NewReader(body))
+			dec.UseNumber()
+			var v interface{}
+			if err := dec.Decode(&v); err != nil {
+				return fmt.Errorf("error parsing json body: %w", err)
+			}
+			val, err := lookupJSONPath(v, path)
+			if err != nil {
+				return err
+			}
+			actual, ok := val.(string)
+			if !ok {
+				b, err := json.Marshal(val)
+				if err != nil {
+					return fmt.Errorf("error encoding value at %s: %w", path, err)
+				}
+				actual = string(b)
+			}
+			if actual != expected {
+				return fmt.Errorf("json value at %s was %s; want: %s", path, actual, expected)
+			}
+			return nil
+		})
+	}
+}
+
+// lookupJSONPath resolves a dotted path of object keys and array indices against the provided unmarshalled JSON value.
+func lookupJSONPath(v interface{}, path string) (interface{}, error) {
+	if path == "" {
+		return v, nil
+	}
+	for i, seg := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			val, ok := node[seg]
+			if !ok {
+				return nil, fmt.Errorf("json path %s did not resolve: no key %s", path, seg)
+			}
+			v = val
+		case []interface{}:
+			idx, err := strconv.Atoi(seg)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, fmt.Errorf("json path %s did not resolve: invalid index %s into array of length %d",
+					path, seg, len(node))
+			}
+			v = node[idx]
+		default:
+			return nil, fmt.Errorf("json path %s did not resolve: %s is not an object or array",
+				path, strings.Join(strings.Split(path, ".")[:i], "."))
+		}
+	}
+	return v, nil
+}
+
+// AssertBodyIsGzip asserts that all requests passed to this fixture have a body which is valid gzip-compressed data,
+// regardless of the Content-Encoding declared by the request.
+func AssertBodyIsGzip() FixtureOpt {
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			body, err := readBody(req)
+			if err != nil {
+				return fmt.Errorf("error reading request body: %w", err)
+			}
+			if len(body) == 0 {
+				return fmt.Errorf("%w; expected a gzip body", errEmptyBody)
+			}
+			if len(body) < 2 || body[0] != 0x1f || body[1] != 0x8b {
+				return errors.New("body did not begin with gzip header")
+			}
+			zr, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				return fmt.Errorf("error reading gzip header: %w", err)
+			}
+			if _, err := io.Copy(io.Discard, zr); err != nil {
+				return fmt.Errorf("error decompressing body: %w", err)
+			}
+			return nil
+		})
+	}
+}
+
+// AssertFormValue asserts that all requests passed to this fixture include a form field with the provided key and
+// value, either in the URL query or in an application/x-www-form-urlencoded body.
+func AssertFormValue(key, value string) FixtureOpt {
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			if _, err := readBody(req); err != nil {
+				return fmt.Errorf("error reading request body: %w", err)
+			}
+			err := req.ParseForm()
+			rewindBody(req)
+			if err != nil {
+				return fmt.Errorf("error parsing form: %w", err)
+			}
+			vals, ok := req.Form[key]
+			if !ok {
+				return fmt.Errorf("form field %s was missing", key)
+			}
+			for _, v := range vals {
+				if v == value {
+					return nil
+				}
+			}
+			return fmt.Errorf("form field %s had values %v; want: %s", key, vals, value)
+		})
+	}
+}
+
+// AssertBodyIsFormEncoded asserts that all requests passed to this fixture declare a Content-Type of
+// application/x-www-form-urlencoded, and have a body which parses cleanly into form values.
+func AssertBodyIsFormEncoded() FixtureOpt {
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			ct := req.Header.Get("Content-Type")
+			mediaType, _, err := mime.ParseMediaType(ct)
+			if err != nil || mediaType != "application/x-www-form-urlencoded" {
+				return fmt.Errorf("Content-Type was %q; want: application/x-www-form-urlencoded", ct)
+			}
+			body, err := readBody(req)
+			if err != nil {
+				return fmt.Errorf("error reading request body: %w", err)
+			}
+			if _, err := url.ParseQuery(string(body)); err != nil {
+				return fmt.Errorf("error parsing form body: %w", err)
+			}
+			return nil
+		})
+	}
+}
+
+// AssertMultipartFieldCount asserts that all requests passed to this fixture have a multipart body containing exactly
+// n parts.
+func AssertMultipartFieldCount(n int) FixtureOpt {
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			mediaType, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+			if err != nil {
+				return fmt.Errorf("error parsing Content-Type: %w", err)
+			}
+			if !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+				return fmt.Errorf("expected multipart Content-Type with boundary; got: %s", req.Header.Get("Content-Type"))
+			}
+			body, err := readBody(req)
+			if err != nil {
+				return fmt.Errorf("error reading request body: %w", err)
+			}
+			r := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+			var count int
+			for {
+				_, err := r.NextPart()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					return fmt.Errorf("error reading multipart body: %w", err)
+				}
+				count++
+			}
+			if count != n {
+				return fmt.Errorf("multipart body had %d parts; want: %d", count, n)
+			}
+			return nil
+		})
+	}
+}
+
+// AssertMultipartField asserts that all requests passed to this fixture have a multipart/form-data body containing a
+// text field with the provided name and value.
+func AssertMultipartField(name, value string) FixtureOpt {
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			form, err := parseMultipartForm(req)
+			if err != nil {
+				return err
+			}
+			vals, ok := form.Value[name]
+			if !ok {
+				return fmt.Errorf("multipart field %s was missing", name)
+			}
+			for _, v := range vals {
+				if v == value {
+					return nil
+				}
+			}
+			return fmt.Errorf("multipart field %s had values %v; want: %s", name, vals, value)
+		})
+	}
+}
+
+// AssertMultipartFileName asserts that all requests passed to this fixture have a multipart/form-data body containing a
+// file with the provided filename under the provided field. If several files are uploaded under the field, any of them
+// may match.
+func AssertMultipartFileName(field, filename string) FixtureOpt {
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			form, err := parseMultipartForm(req)
+			if err != nil {
+				return err
+			}
+			files, ok := form.File[field]
+			if !ok {
+				return fmt.Errorf("multipart file field %s was missing", field)
+			}
+			names := make([]string, 0, len(files))
+			for _, fh := range files {
+				if fh.Filename == filename {
+					return nil
+				}
+				names = append(names, fh.Filename)
+			}
+			return fmt.Errorf("multipart file field %s had files %v; want: %s", field, names, filename)
+		})
+	}
+}
+
+// maxMultipartMemory is the maximum number of bytes of multipart file parts stored in memory while parsing requests;
+// the remainder are stored in temporary files.
+const maxMultipartMemory = 32 << 20
+
+// parseMultipartForm parses the multipart/form-data body of the provided request, leaving the body available to be read
+// again.
+func parseMultipartForm(req *http.Request) (*multipart.Form, error) {
+	if _, err := readBody(req); err != nil {
+		return nil, fmt.Errorf("error reading request body: %w", err)
+	}
+	err := req.ParseMultipartForm(maxMultipartMemory)
+	rewindBody(req)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing multipart form: %w", err)
+	}
+	return req.MultipartForm, nil
+}
+
+// AssertXMLHasElement asserts that all requests passed to this fixture have an XML body containing an element at the
+// provided path. Paths are made of element names separated by dots, starting from the root element, e.g.
+// "Envelope.Body.Request". Namespaces are ignored when matching element names.
+func AssertXMLHasElement(xpathLike string) FixtureOpt {
+	want := strings.Split(xpathLike, ".")
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			body, err := readBody(req)
+			if err != nil {
+				return fmt.Errorf("error reading request body: %w", err)
+			}
+			if len(body) == 0 {
+				return fmt.Errorf("%w; expected an xml body", errEmptyBody)
+			}
+			dec := xml.NewDecoder(bytes.NewReader(body))
+			var stack []string
+			for {
+				tok, err := dec.Token()
+				if errors.Is(err, io.EOF) {
+					return fmt.Errorf("xml body did not contain element %s", xpathLike)
+				}
+				if err != nil {
+					return fmt.Errorf("error parsing xml body: %w", err)
+				}
+				switch tok := tok.(type) {
+				case xml.StartElement:
+					stack = append(stack, tok.Name.Local)
+					if len(stack) == len(want) && strings.Join(stack, ".") == xpathLike {
+						return nil
+					}
+				case xml.EndElement:
+					stack = stack[:len(stack)-1]
+				}
+			}
+		})
+	}
+}
+
+// multiFixture serves a fixed sequence of fixtures. Each fixture is served once, except for the final fixture, which is
+// repeated forever.
+type multiFixture struct {
+	fixtures []F
+
+	mu     sync.Mutex
+	served int
+	baseFixture
+}
+
+// Run exchanges the provided request for an appropriate response.
+func (mf *multiFixture) Run(t testing.TB, req *http.Request) *http.Response {
+	t.Helper()
+	mf.baseFixture.assertAll(t, req)
+	mf.mu.Lock()
+	curr := mf.served
+	mf.served++
+	mf.mu.Unlock()
+	if curr >= len(mf.fixtures) {
+		curr = len(mf.fixtures) - 1
+	}
+	return mf.fixtures[curr].Run(t, req)
+}
+
+// Progress reports the number of requests served by this Seq, and the number of fixtures it was created with.
+func (mf *multiFixture) Progress() (served, total int) {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+	return mf.served, len(mf.fixtures)
+}
+
+// selectFixture delegates each request to a sub-fixture chosen based on the incoming request.
+type selectFixture struct {
+	choose func(req *http.Request) F
+	baseFixture
+}
+
+// Run exchanges the provided request for an appropriate response.
+func (sf *selectFixture) Run(t testing.TB, req *http.Request) *http.Response {
+	t.Helper()
+	sf.baseFixture.assertAll(t, req)
+	return sf.choose(req).Run(t, req)
+}
+
+// slowReaderFixture reads request bodies at a limited rate before responding.
+type slowReaderFixture struct {
+	readRate int
+	baseFixture
+}
+
+// Run exchanges the provided request for an appropriate response.
+func (sf *slowReaderFixture) Run(t testing.TB, req *http.Request) *http.Response {
+	t.Helper()
+	if req.Body != nil {
+		var body bytes.Buffer
+		if _, err := io.Copy(&body, throttle(req.Context(), req.Body, sf.readRate)); err != nil {
+			return nil // the client gave up on sending the body.
+		}
+		req.Body = io.NopCloser(&body)
+	}
+	return sf.baseFixture.respond(t, req)
+}
+
+// templateFixture renders its response body from a template for each request.
+type templateFixture struct {
+	tmpl *template.Template
+	baseFixture
+}
+
+// Run exchanges the provided request for an appropriate response.
+func (tf *templateFixture) Run(t testing.TB, req *http.Request) *http.Response {
+	t.Helper()
+	resp := tf.baseFixture.respond(t, req)
+	if resp == nil {
+		return nil
+	}
+	var body bytes.Buffer
+	if err := tf.tmpl.Execute(&body, req); err != nil {
+		t.Logf("error executing template: %v", err)
+		t.Fail()
+		resp.StatusCode = http.StatusInternalServerError
+		return resp
+	}
+	resp.Body = io.NopCloser(&body)
+	return resp
+}
+
+// funcFixture computes its response status and body from each request.
+type funcFixture struct {
+	fn func(req *http.Request) (int, []byte)
+	baseFixture
+}
+
+// Run exchanges the provided request for an appropriate response.
+func (ff *funcFixture) Run(t testing.TB, req *http.Request) *http.Response {
+	t.Helper()
+	resp := ff.baseFixture.respond(t, req)
+	if resp == nil {
+		return nil
+	}
+	code, body := ff.fn(req)
+	resp.StatusCode = code
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp
+}
+
+// versionedFixture serves a versioned resource, which is replaced by each PUT request.
+type versionedFixture struct {
+	mu      sync.Mutex
+	version int
+	body    []byte
+	baseFixture
+}
+
+// Run exchanges the provided request for an appropriate response.
+func (vf *versionedFixture) Run(t testing.TB, req *http.Request) *http.Response {
+	t.Helper()
+	resp := vf.baseFixture.respond(t, req)
+	if resp == nil {
+		return nil
+	}
+	if resp.Header == nil {
+		resp.Header = make(http.Header)
+	}
+	vf.mu.Lock()
+	defer vf.mu.Unlock()
+	etag := fmt.Sprintf(`"%d"`, vf.version)
+	switch req.Method {
+	case http.MethodGet:
+		resp.Header.Set("ETag", etag)
+		resp.Body = io.NopCloser(bytes.NewReader(vf.body))
+	case http.MethodPut:
+		if match := req.Header.Values("If-Match"); len(match) > 0 && !ifMatch(match, etag) {
+			resp.StatusCode = http.StatusPreconditionFailed
+			return resp
+		}
+		body, err := readBody(req)
+		if err != nil {
+			t.Logf("error reading request body: %v", err)
+			t.Fail()
+			resp.StatusCode = http.StatusInternalServerError
+			return resp
+		}
+		vf.version++
+		vf.body = body
+		resp.StatusCode = http.StatusNoContent
+		resp.Header.Set("ETag", fmt.Sprintf(`"%d"`, vf.version))
+	default:
+		resp.StatusCode = http.StatusMethodNotAllowed
+		resp.Header.Set("Allow", "GET, PUT")
+	}
+	return resp
+}
+
+// cacheableFixture responds to conditional requests using an entity tag and a modification time.
+type cacheableFixture struct {
+	body    []byte
+	etag    string
+	modTime time.Time
+	baseFixture
+}
+
+// Run exchanges the provided request for an appropriate response.
+func (cf *cacheableFixture) Run(t testing.TB, req *http.Request) *http.Response {
+	t.Helper()
+	resp := cf.baseFixture.respond(t, req)
+	if resp == nil {
+		return nil
+	}
+	if cf.notModified(req) {
+		resp.StatusCode = http.StatusNotModified
+		return resp
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(cf.body))
+	return resp
+}
+
+// notModified returns true if the conditional headers of the provided request show that the client's cached copy is
+// current. As per RFC 9110, If-Modified-Since is ignored when If-None-Match is present.
+func (cf *cacheableFixture) notModified(req *http.Request) bool {
+	if inm := req.Header.Values("If-None-Match"); len(inm) > 0 {
+		for _, v := range inm {
+			for _, tag := range strings.Split(v, ",") {
+				tag = strings.TrimSpace(tag)
+				if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(cf.etag, "W/") {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	ims, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	return err == nil && !cf.modTime.After(ims)
+}
+
+// ifMatch returns true if the provided If-Match header values match etag, as per RFC 9110: either "*", or a
+// comma-separated list of entity tags containing etag. Weak entity tags never match.
+func ifMatch(vals []string, etag string) bool {
+	for _, v := range vals {
+		for _, tag := range strings.Split(v, ",") {
+			if tag = strings.TrimSpace(tag); tag == "*" || tag == etag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hijackFixture takes over the underlying connection of each request.
+type hijackFixture struct {
+	fn func(conn net.Conn)
+	baseFixture
+}
+
+// Run hijacks the connection of the provided request, and always returns a nil response.
+func (hf *hijackFixture) Run(t testing.TB, req *http.Request) *http.Response {
+	t.Helper()
+	hf.baseFixture.assertAll(t, req)
+	conn := hijack(t, req)
+	if conn == nil {
+		return nil
+	}
+	defer conn.Close()
+	hf.fn(conn)
+	return nil
+}
+
+// hijack takes over the connection of the provided request, which must be served by a Server. It fails the test and
+// returns nil if the connection cannot be hijacked.
+func hijack(t testing.TB, req *http.Request) net.Conn {
+	t.Helper()
+	ex := exchangeFrom(req)
+	if ex == nil {
+		t.Logf("httpfixture: Hijack fixtures must be served by a Server")
+		t.Fail()
+		return nil
+	}
+	hj, ok := ex.rw.(http.Hijacker)
+	if !ok {
+		t.Logf("httpfixture: connection does not support hijacking")
+		t.Fail()
+		return nil
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		t.Logf("httpfixture: error hijacking connection: %v", err)
+		t.Fail()
+		return nil
+	}
+	if brw.Reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: brw.Reader} // the client sent bytes which were already read by the server.
+	}
+	return conn
+}
+
+// bufferedConn is a net.Conn whose reads are served from a buffered reader over the connection.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (bc *bufferedConn) Read(p []byte) (int, error) {
+	return bc.r.Read(p)
+}
+
+// memFixture is for fixtures whose response bodies fit in memory.
+type memFixture struct {
+	body []byte
+	baseFixture
+}
+
+// Run exchanges the provided request for an appropriate response.
+func (s *memFixture) Run(t testing.TB, req *http.Request) *http.Response {
+	t.Helper()
+	resp := s.baseFixture.respond(t, req)
+	if resp == nil {
+		return nil
+	}
+	body := s.body
+	if s.ranges {
+		body = serveRange(resp, req, body)
+	}
+	resp.Body = io.NopCloser(bytes.NewBuffer(body))
+	return resp
+}
+
+// serveRange returns the part of body requested by the Range header of the provided GET request, updating resp with
+// the status and headers of a partial or unsatisfiable response. Requests without a single valid byte range receive
+// the full body.
+func serveRange(resp *http.Response, req *http.Request, body []byte) []byte {
+	if resp.Header == nil {
+		resp.Header = make(http.Header)
+	}
+	resp.Header.Set("Accept-Ranges", "bytes")
+	spec := req.Header.Get("Range")
+	if spec == "" || req.Method != http.MethodGet {
+		return body
+	}
+	start, end, err := parseRange(spec, len(body))
+	if errors.Is(err, errRangeNotSatisfiable) {
+		resp.StatusCode = http.StatusRequestedRangeNotSatisfiable
+		resp.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", len(body)))
+		return nil
+	}
+	if err != nil {
+		return body // invalid or multiple ranges are ignored, as permitted by RFC 9110.
+	}
+	resp.StatusCode = http.StatusPartialContent
+	resp.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end-1, len(body)))
+	return body[start:end]
+}
+
+// errRangeNotSatisfiable is returned by parseRange for valid ranges which do not overlap the body.
+var errRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// parseRange parses a Range header containing a single byte range, such as "bytes=0-9", "bytes=10-", or "bytes=-5",
+// returning the half-open interval [start, end) it selects from a body of the provided size.
+func parseRange(spec string, size int) (start, end int, err error) {
+	r, ok := strings.CutPrefix(spec, "bytes=")
+	if !ok || strings.Contains(r, ",") {
+		return 0, 0, fmt.Errorf("unsupported range %q", spec)
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(r), "-")
+	if !ok || (first == "" && last == "") {
+		return 0, 0, fmt.Errorf("invalid range %q", spec)
+	}
+	if first == "" { // a suffix range, selecting the last bytes of the body.
+		n, err := strconv.Atoi(last)
+		if err != nil || n < 0 {
+			return 0, 0, fmt.Errorf("invalid range %q", spec)
+		}
+		if n == 0 {
+			return 0, 0, errRangeNotSatisfiable
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size, nil
+	}
+	start, err = strconv.Atoi(first)
+	if err != nil || start < 0 {
+		return 0, 0, fmt.Errorf("invalid range %q", spec)
+	}
+	end = size
+	if last != "" {
+		l, err := strconv.Atoi(last)
+		if err != nil || l < start {
+			return 0, 0, fmt.Errorf("invalid range %q", spec)
+		}
+		if l+1 < end {
+			end = l + 1
+		}
+	}
+	if start >= size {
+		return 0, 0, errRangeNotSatisfiable
+	}
+	return start, end, nil
+}
+
+// gzipFixture responds with a gzip-compressed body to clients which accept it, and an uncompressed body otherwise.
+type gzipFixture struct {
+	plain      []byte
+	compressed []byte
+	baseFixture
+}
+
+// Run exchanges the provided request for an appropriate response.
+func (gf *gzipFixture) Run(t testing.TB, req *http.Request) *http.Response {
+	t.Helper()
+	resp := gf.baseFixture.respond(t, req)
+	if resp == nil {
+		return nil
+	}
+	if !acceptsGzip(req) {
+		resp.Body = io.NopCloser(bytes.NewReader(gf.plain))
+		return resp
+	}
+	if resp.Header == nil {
+		resp.Header = make(http.Header)
+	}
+	resp.Header.Set("Content-Encoding", "gzip")
+	resp.Body = io.NopCloser(bytes.NewReader(gf.compressed))
+	return resp
+}
+
+// acceptsGzip returns true if the Accept-Encoding header of the provided request allows a gzip-encoded response.
+func acceptsGzip(req *http.Request) bool {
+	for _, v := range req.Header.Values("Accept-Encoding") {
+		for _, coding := range strings.Split(v, ",") {
+			name, params, _ := strings.Cut(coding, ";")
+			name = strings.TrimSpace(name)
+			if !strings.EqualFold(name, "gzip") && name != "*" {
+				continue
+			}
+			if key, val, ok := strings.Cut(params, "="); ok && strings.TrimSpace(key) == "q" {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil && q == 0 {
+					continue // the client explicitly refused gzip.
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+type baseFixture struct {
+	route        string
+	method       string
+	responseCode int
+	header       http.Header
+	trailer      http.Header
+	chunked      bool
+	bandwidth    int
+	delay        time.Duration
+	interval     time.Duration // the delay between events written by an SSE fixture.
+	interim      []interimResponse
+	ranges       bool // whether Range requests are served with partial content.
+	latency      Distribution
+	clock        Clock
+	rng          *rand.Rand
+	errorFields  *jsonErrorFields // the field names set by WithErrorFields; nil if unset. Only read by JSONError.
+	assertions   []assert
+	matchers     []func(req *http.Request) bool
+}
+
+func (bf *baseFixture) Run(t testing.TB, req *http.Request) *http.Response {
+	t.Helper()
+	return bf.respond(t, req)
+}
+
+// respond runs all assertions against the provided request, waits for any configured delay or latency, and creates a
+// new response. The returned response is nil if the request's context is done before the delay elapses.
+func (bf *baseFixture) respond(t testing.TB, req *http.Request) *http.Response {
+	t.Helper()
+	bf.assertAll(t, req)
+	delay := bf.delay
+	if bf.latency != nil {
+		delay += bf.latency.Sample()
+	}
+	if !sleep(req.Context(), delay) {
+		return nil
+	}
+	if ex := exchangeFrom(req); ex != nil {
+		if bf.bandwidth > 0 {
+			ex.bandwidth = bf.bandwidth
+		}
+		for _, ir := range bf.interim {
+			ir.write(ex.rw)
+		}
+	}
+	return bf.response()
+}
+
+// now returns the current time according to this fixture's clock.
+func (bf *baseFixture) now() time.Time {
+	if bf.clock == nil {
+		return systemClock{}.Now()
+	}
+	return bf.clock.Now()
+}
+
+// interimResponse is an informational (1xx) response written before the final response.
+type interimResponse struct {
+	status int
+	header http.Header
+}
+
+// write writes this interim response to rw, without leaving its headers in the final response.
+func (ir interimResponse) write(rw http.ResponseWriter) {
+	h := rw.Header()
+	saved := make(http.Header, len(ir.header))
+	for key, vals := range ir.header {
+		key = http.CanonicalHeaderKey(key)
+		if prev, ok := h[key]; ok {
+			saved[key] = prev
+		}
+		h[key] = vals
+	}
+	rw.WriteHeader(ir.status)
+	for key := range ir.header {
+		key = http.CanonicalHeaderKey(key)
+		if prev, ok := saved[key]; ok {
+			h[key] = prev
+		} else {
+			delete(h, key)
+		}
+	}
+}
+
+// matcher is implemented by fixtures which further restrict the requests they match, beyond route and method.
+type matcher interface {
+	// matches returns true if the provided request matches all of this fixture's constraints.
+	matches(req *http.Request) bool
+	// constraints returns the number of constraints placed on matching requests.
+	constraints() int
+}
+
+func (bf *baseFixture) matches(req *http.Request) bool {
+	for _, m := range bf.matchers {
+		if !m(req) {
+			return false
+		}
+	}
+	return true
+}
+
+func (bf *baseFixture) constraints() int {
+	return len(bf.matchers)
+}
+
+// assertAll runs all request assertions against the provided incoming request. It fails and halts the current test if
+// any assertion fails.
+func (bf *baseFixture) assertAll(t testing.TB, req *http.Request) {
+	t.Helper()
+	var failedAssert bool
+	for _, a := range bf.assertions {
+		rewindBody(req) // each assertion sees the full body, which is only buffered by the first to read it.
+		if err := a(req); err != nil {
+			t.Logf("request failed assertion: %v", err)
+			failedAssert = true
+			if ex := exchangeFrom(req); ex != nil {
+				ex.failures = append(ex.failures, err)
+			}
+		}
+	}
+	rewindBody(req)
+	if failedAssert {
+		t.Fail()
+	}
+}
+
+// Response creates a new response populated with fields set in this baseFixture.
+func (bf *baseFixture) response() *http.Response {
+	resp := &http.Response{
+		StatusCode: bf.responseCode,
+		Header:     bf.header.Clone(),
+		Trailer:    bf.trailer.Clone(),
+	}
+	if bf.chunked {
+		resp.TransferEncoding = []string{"chunked"}
+	}
+	return resp
+}
+
+// Route returns the route used to trigger this fixture.
+func (bf *baseFixture) Route() string {
+	return bf.route
+}
+
+// Method returns the HTTP method used to trigger this fixture.
+func (bf *baseFixture) Method() string {
+	return bf.method
+}
+
+type Server struct {
+	*httptest.Server
+	t testing.TB
+
+	mu        sync.Mutex
+	routes    []F
+	fallback  F
+	unmatched *unmatchedResponse
+	onRequest func(rw http.ResponseWriter, req *http.Request) bool
+	asserts   []assert
+	calls     []int
+	order     []string
+	failures  []error
+	requests  []recordedRequest
+	arrived   chan struct{} // closed and replaced whenever a request is recorded.
+
+	recorder   *recordFixture
+	recordFile string
+}
+
+// NewServer creates a new httpfixture.Server which responds to requests with the provided fixtures.
+func NewServer(fixtures ...F) *Server {
+	var result Server
+	result.Server = httptest.NewUnstartedServer(&result)
+	for _, f := range fixtures {
+		result.routes = append(result.routes, f)
+	}
+	result.calls = make([]int, len(result.routes))
+	result.arrived = make(chan struct{})
+	return &result
+}
+
+// Add registers additional fixtures with this server. It is safe to call before or after the server is started.
+func (s *Server) Add(fixtures ...F) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes = append(s.routes, fixtures...)
+	s.calls = append(s.calls, make([]int, len(fixtures))...)
+}
+
+// Start starts the server, reporting assertions using the provided testing.TB.
+func (s *Server) Start(t testing.TB) {
+	s.t = t
+	s.Server.Start()
+}
+
+// StartTLS starts the server in TLS mode, reporting assertions using the provided testing.TB.
+func (s *Server) StartTLS(t testing.TB) {
+	s.t = t
+	s.Server.StartTLS()
+}
+
+// SetClientCAs requires clients of this server to present a TLS certificate signed by one of the certificate
+// authorities in the provided pool, for testing mutual TLS. It must be called before StartTLS or StartHTTP2.
+func (s *Server) SetClientCAs(pool *x509.CertPool) {
+	if s.Server.TLS == nil {
+		s.Server.TLS = &tls.Config{}
+	}
+	s.Server.TLS.ClientCAs = pool
+	s.Server.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+}
+
+// StartHTTP2 starts the server in TLS mode with HTTP/2 enabled, reporting assertions using the provided testing.TB.
+// Clients must negotiate HTTP/2 via TLS; the client returned by Client is configured to do so.
+func (s *Server) StartHTTP2(t testing.TB) {
+	s.Server.EnableHTTP2 = true
+	s.StartTLS(t)
+}
+
+// Handler returns an http.Handler which serves this server's fixtures, reporting assertions using the provided
+// testing.TB. It can be used to mount fixtures within another handler, such as an http.ServeMux, without starting the
+// underlying httptest.Server.
+func (s *Server) Handler(t testing.TB) http.Handler {
+	s.t = t
+	return s
+}
+
+// Close closes the underlying httptest.Server. Servers created by NewRecordReplayServer in record mode save their
+// recording when closed.
+func (s *Server) Close() {
+	s.Server.Close()
+	if s.recorder != nil {
+		if err := s.recorder.save(s.recordFile); err != nil && s.t != nil {
+			s.t.Errorf("error saving recording: %v", err)
+		}
+	}
+}
+
+// SetDefault sets a fixture which serves all requests not matched by any other fixture, in place of the default 404
+// Not Found response. The route and method of the default fixture are ignored.
+func (s *Server) SetDefault(f F) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallback = f
+}
+
+// SetUnmatchedResponse sets the status code and body of responses to requests not matched by any fixture, in place of
+// the 404 Not Found response written by http.NotFound. It has no effect if a default fixture is set using SetDefault.
+func (s *Server) SetUnmatchedResponse(status int, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unmatched = &unmatchedResponse{status: status, body: body}
+}
+
+// OnRequest sets a hook which is called with each request before it is dispatched to a fixture, e.g. to gate all
+// routes behind custom authentication. If the hook returns true, it has handled the request, and no fixture is run.
+// Requests handled by the hook are still recorded, but are not counted against any fixture.
+func (s *Server) OnRequest(hook func(rw http.ResponseWriter, req *http.Request) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onRequest = hook
+}
+
+// AssertEach registers an assertion which is run against every request matched by a fixture on this server, before
+// the fixture runs, e.g. to require an API key on all requests. As with fixture assertions, an error returned by fn
+// fails the test, and is reported by AssertionFailures.
+func (s *Server) AssertEach(fn func(req *http.Request) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.asserts = append(s.asserts, fn)
+}
+
+// unmatchedResponse is written in response to requests not matched by any fixture.
+type unmatchedResponse struct {
+	status int
+	body   []byte
+}
+
+// Fixtures returns a copy of the list of fixtures registered with this server, in registration order.
+func (s *Server) Fixtures() []F {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]F(nil), s.routes...)
+}
+
+// Times returns the number of requests served by fixtures with the provided route and method.
+func (s *Server) Times(route, method string) int {
+	route = standardizePath(route)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result int
+	for i, f := range s.routes {
+		if f.Route() == route && strings.EqualFold(f.Method(), method) {
+			result += s.calls[i]
+		}
+	}
+	return result
+}
+
+// AssertTimes asserts that fixtures with the provided route and method served exactly want requests, failing the
+// provided test otherwise.
+func (s *Server) AssertTimes(t testing.TB, route, method string, want int) {
+	t.Helper()
+	if got := s.Times(route, method); got != want {
+		t.Errorf("want %s %s to be called %d times; got: %d", method, route, want, got)
+	}
+}
+
+// CallOrder returns a description of each request matched by a fixture, in the order the requests arrived. Each
+// description contains the request method and the route of the matched fixture, such as "GET /users".
+func (s *Server) CallOrder() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.order...)
+}
+
+// AssertEqualCalls asserts that fixtures with routeA served exactly as many requests as fixtures with routeB, regardless
+// of method, failing the provided test otherwise. It can be used to verify that a client balances or mirrors requests
+// across backends.
+func (s *Server) AssertEqualCalls(t testing.TB, routeA, routeB string) {
+	t.Helper()
+	if a, b := s.routeCalls(routeA), s.routeCalls(routeB); a != b {
+		t.Errorf("want %s and %s to be called the same number of times; got: %d and %d", routeA, routeB, a, b)
+	}
+}
+
+// routeCalls returns the number of requests served by fixtures with the provided route, regardless of method.
+func (s *Server) routeCalls(route string) int {
+	route = standardizePath(route)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result int
+	for i, f := range s.routes {
+		if f.Route() == route {
+			result += s.calls[i]
+		}
+	}
+	return result
+}
+
+// recordedRequest is a request served by a Server, along with its body.
+type recordedRequest struct {
+	req  *http.Request
+	body []byte
+}
+
+// record records the provided request and body as served by this server.
+func (s *Server) record(req *http.Request, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, recordedRequest{req: req.Clone(context.Background()), body: body})
+	close(s.arrived)
+	s.arrived = make(chan struct{})
+}
+
+// Requests returns copies of all requests which have been served by this server so far, in the order they were
+// served, including requests which did not match any fixture. The body of each returned request contains the full body
+// sent by the client.
+func (s *Server) Requests() []*http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]*http.Request, 0, len(s.requests))
+	for _, r := range s.requests {
+		req := r.req.Clone(context.Background())
+		req.Body = io.NopCloser(bytes.NewReader(r.body))
+		result = append(result, req)
+	}
+	return result
+}
+
+// WaitForRequests blocks until at least n requests have been served by this server, or the provided context is done.
+// The returned error describes how many requests were served if the context is done first.
+func (s *Server) WaitForRequests(ctx context.Context, n int) error {
+	for {
+		s.mu.Lock()
+		got, arrived := len(s.requests), s.arrived
+		s.mu.Unlock()
+		if got >= n {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("httpfixture: waited for %d requests, but only %d arrived: %w", n, got, ctx.Err())
+		case <-arrived:
+		}
+	}
+}
+
+// AssertNotCalled asserts that fixtures with the provided route and method have not served any requests, failing the
+// provided test otherwise. It is intended to be used after the code under test has run.
+func (s *Server) AssertNotCalled(t testing.TB, route, method string) {
+	t.Helper()
+	if got := s.Times(route, method); got != 0 {
+		t.Errorf("want %s %s not to be called; was called %d times", method, route, got)
+	}
+}
+
+// AssertionFailures returns messages describing every failed assertion seen by this server so far, in the order they
+// occurred.
+func (s *Server) AssertionFailures() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]string, 0, len(s.failures))
+	for _, err := range s.failures {
+		result = append(result, err.Error())
+	}
+	return result
+}
+
+// Err returns an error joining every failed assertion seen by this server so far, in the order they occurred, or nil
+// if no assertion has failed. Each joined error wraps the error returned by the failed assertion.
+func (s *Server) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return errors.Join(s.failures...)
+}
+
+// ErrNotStarted is reported when a Server is used before it has been started. It is the value of the panic raised by
+// URL, and can be matched using errors.Is.
+var ErrNotStarted = errors.New("httpfixture: server not started; call Start(t) first")
+
+// URL retrieves the URL of this server, once it's been started. It panics if the server has not been started.
+func (s *Server) URL() string {
+	if s.Server.URL == "" {
+		panic(ErrNotStarted)
+	}
+	return s.Server.URL
+}
+
+// Client returns an HTTP client which sends all requests to this server, regardless of the host in the request URL,
+// so that it can be injected into code under test which does not know the server's URL. The Host header of requests
+// is left unchanged. As with httptest.Server.Client, the client trusts the server's TLS certificate, and negotiates
+// HTTP/2 if the server was started using StartHTTP2.
+func (s *Server) Client() *http.Client {
+	target, err := url.Parse(s.URL())
+	if err != nil {
+		panic(fmt.Sprintf("httpfixture: error parsing server URL: %v", err))
+	}
+	client := *s.Server.Client()
+	client.Transport = &rewriteTransport{target: target, inner: client.Transport}
+	return &client
+}
+
+// rewriteTransport is an http.RoundTripper which sends all requests to the scheme and host of a target URL.
+type rewriteTransport struct {
+	target *url.URL
+	inner  http.RoundTripper
+}
+
+// RoundTrip sends the provided request to the target.
+func (rt *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if req.Host == "" {
+		req.Host = req.URL.Host
+	}
+	req.URL.Scheme = rt.target.Scheme
+	req.URL.Host = rt.target.Host
+	return rt.inner.RoundTrip(req)
+}
+
+type assert func(req *http.Request) error
+
+// ServeHTTP implements the http.Handler interface.
+func (s *Server) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if s.t == nil {
+		http.Error(rw, ErrNotStarted.Error(), http.StatusInternalServerError)
+		return
+	}
+	if req.URL == nil {
+		s.t.Logf("nil request URL")
+		s.t.Fail()
+		return
+	}
+	var body bytes.Buffer
+	if req.Body != nil {
+		tee := io.TeeReader(req.Body, &body)
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{tee, req.Body}
+		defer func() {
+			_, _ = io.Copy(io.Discard, tee) // ensure the full body is recorded.
+			s.record(req, body.Bytes())
+		}()
+	} else {
+		defer s.record(req, nil)
+	}
+	s.mu.Lock()
+	hook := s.onRequest
+	s.mu.Unlock()
+	if hook != nil && hook(rw, req) {
+		return
+	}
+	f := s.dispatch(req)
 	if f == nil {
-		http.NotFound(rw, req)
+		s.t.Logf("httpfixture: no fixture matched %s %s; registered routes: %s", req.Method, req.URL.Path, s.describeRoutes())
+		s.mu.Lock()
+		unmatched := s.unmatched
+		s.mu.Unlock()
+		if unmatched == nil {
+			http.NotFound(rw, req)
+			return
+		}
+		rw.WriteHeader(unmatched.status)
+		_, _ = rw.Write(unmatched.body)
 		return
 	}
+
+	ex := &exchange{rw: rw}
+	req = req.WithContext(context.WithValue(req.Context(), exchangeKey{}, ex))
+	s.mu.Lock()
+	server := baseFixture{assertions: s.asserts}
+	s.mu.Unlock()
+	server.assertAll(s.t, req)
 	resp := f.Run(s.t, req)
-	if resp == nil {
-		return
+	s.mu.Lock()
+	for _, err := range ex.failures {
+		s.failures = append(s.failures, fmt.Errorf("%s %s: %w", req.Method, req.URL.Path, err))
+	}
+	s.mu.Unlock()
+	if resp == nil || req.Context().Err() != nil {
+		return // the fixture chose not to respond, or the client went away.
+	}
+	if resp.Body != nil {
+		defer resp.Body.Close()
 	}
 	for key, vals := range resp.Header {
 		for _, v := range vals {
-			resp.Header.Add(key, v)
+			rw.Header().Add(key, v)
 		}
 	}
-	rw.WriteHeader(resp.StatusCode)
-	if resp.Body == nil {
+	for key := range resp.Trailer {
+		rw.Header().Add("Trailer", key)
+	}
+	chunked := isChunked(resp) || len(resp.Trailer) > 0 // trailers can only be sent with chunked encoding.
+	if chunked {
+		rw.Header().Del("Content-Length")
+	}
+	if req.Method == http.MethodHead {
+		// HEAD responses have no body, but should report the Content-Length a GET would have received.
+		if !chunked && resp.Body != nil && rw.Header().Get("Content-Length") == "" {
+			n, err := io.Copy(io.Discard, resp.Body)
+			if err != nil {
+				s.t.Logf("failed to read response body: %v", err)
+				s.t.Fail()
+				return
+			}
+			rw.Header().Set("Content-Length", strconv.FormatInt(n, 10))
+		}
+		rw.WriteHeader(resp.StatusCode)
 		return
 	}
-	if _, err := io.Copy(rw, resp.Body); err != nil {
+	rw.WriteHeader(resp.StatusCode)
+	var out io.Reader = resp.Body
+	if ex.bandwidth > 0 && out != nil {
+		out = throttle(req.Context(), out, ex.bandwidth)
+	}
+	if err := writeBody(rw, out, chunked || ex.bandwidth > 0); err != nil {
+		if req.Context().Err() != nil {
+			return
+		}
 		s.t.Logf("failed to copy response body: %v", err)
 		s.t.Fail()
 		return
 	}
+	for key, vals := range resp.Trailer {
+		rw.Header()[key] = vals
+	}
 	return
 }
 
-func standardizePath(path string) string {
-	if len(path) == 0 {
+// describeRoutes returns a description of the method and route of each fixture registered with this server.
+func (s *Server) describeRoutes() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.routes) == 0 {
+		return "(none)"
+	}
+	routes := make([]string, 0, len(s.routes))
+	for _, f := range s.routes {
+		routes = append(routes, fmt.Sprintf("%s %s", f.Method(), f.Route()))
+	}
+	return strings.Join(routes, ", ")
+}
+
+// exchangeKey is the context key used to store the exchange for a request served by a Server.
+type exchangeKey struct{}
+
+// exchange collects information from fixtures about a single request served by a Server.
+type exchange struct {
+	rw        http.ResponseWriter
+	failures  []error
+	bandwidth int // the rate, in bytes per second, at which to write the response body, if positive.
+}
+
+// exchangeFrom retrieves the exchange for the provided request, or nil if it is not being served by a Server.
+func exchangeFrom(req *http.Request) *exchange {
+	ex, _ := req.Context().Value(exchangeKey{}).(*exchange)
+	return ex
+}
+
+// dispatch returns the fixture which should serve the provided request, counting the call against it. If no fixture
+// matches, the default fixture is returned, which may be nil.
+func (s *Server) dispatch(req *http.Request) F {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.match(req)
+	if i < 0 {
+		return s.fallback
+	}
+	s.calls[i]++
+	s.order = append(s.order, fmt.Sprintf("%s %s", req.Method, s.routes[i].Route()))
+	return s.routes[i]
+}
+
+// match returns the index of the fixture which should serve the provided request, or -1 if no fixture matches. Among
+// the fixtures whose route, method, and matchers all match, the fixture with the longest route is chosen, so that the
+// most specific route wins regardless of registration order. Ties are broken by preferring the fixture with the most
+// matchers, then an exact method over "*", then by registration order.
+func (s *Server) match(req *http.Request) int {
+	var (
+		result = -1
+		best   routeRank
+	)
+	reqPath := standardizePath(req.URL.Path)
+	reqMethod := strings.ToUpper(req.Method)
+	for i, fixture := range s.routes {
+		m := strings.ToUpper(fixture.Method())
+		if !routeMatches(fixture.Route(), reqPath) || (m != "*" && m != reqMethod) {
+			continue
+		}
+		rank := routeRank{prefix: len(fixture.Route()), exactMethod: m != "*"}
+		if mf, ok := fixture.(matcher); ok {
+			if !mf.matches(req) {
+				continue
+			}
+			rank.constraints = mf.constraints()
+		}
+		if result < 0 || rank.beats(best) {
+			result, best = i, rank
+		}
+	}
+	return result
+}
+
+// routeMatches returns true if the provided standardized request path is route, or lies beneath it. Routes match whole
+// path segments, so "/users" matches "/users" and "/users/1", but not "/usersettings"; the route "/" matches all paths.
+func routeMatches(route, reqPath string) bool {
+	if route == "/" || reqPath == route {
+		return true
+	}
+	return strings.HasPrefix(reqPath, route+"/")
+}
+
+// routeRank describes how specifically a fixture matches a request.
+type routeRank struct {
+	prefix      int  // the length of the matching route.
+	constraints int  // the number of matchers placed on the fixture.
+	exactMethod bool // whether the fixture matches the request method exactly, rather than via "*".
+}
+
+// beats returns true if r is a more specific match than other.
+func (r routeRank) beats(other routeRank) bool {
+	if r.prefix != other.prefix {
+		return r.prefix > other.prefix
+	}
+	if r.constraints != other.constraints {
+		return r.constraints > other.constraints
+	}
+	return r.exactMethod && !other.exactMethod
+}
+
+// isChunked returns true if the provided response must be sent using chunked transfer encoding.
+func isChunked(resp *http.Response) bool {
+	for _, te := range resp.TransferEncoding {
+		if te == "chunked" {
+			return true
+		}
+	}
+	return false
+}
+
+// chunkSize is the maximum size of each write made when flushing a response body.
+const chunkSize = 1024
+
+// writeBody copies the provided body to rw. If flush is set, rw is flushed after the response headers and after each
+// write of at most chunkSize bytes, which causes net/http to use chunked transfer encoding.
+func writeBody(rw http.ResponseWriter, body io.Reader, flush bool) error {
+	flusher, ok := rw.(http.Flusher)
+	if !flush || !ok {
+		if body == nil {
+			return nil
+		}
+		_, err := io.Copy(rw, body)
+		return err
+	}
+	flusher.Flush()
+	if body == nil {
+		return nil
+	}
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, err := rw.Write(buf[:n]); err != nil {
+				return err
+			}
+			flusher.Flush()
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// sleep waits for the provided duration, returning false if the provided context is done before it elapses.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// throttledReader limits the rate at which bytes can be read from an underlying reader.
+type throttledReader struct {
+	ctx  context.Context
+	r    io.Reader
+	rate int
+}
+
+// throttle returns a reader which reads from r at no more than rate bytes per second, until ctx is done. Reads are
+// limited in size, so that bytes are delivered roughly every 100ms.
+func throttle(ctx context.Context, r io.Reader, rate int) io.Reader {
+	return &throttledReader{ctx: ctx, r: r, rate: rate}
+}
+
+func (tr *throttledReader) Read(p []byte) (int, error) {
+	if tr.rate <= 0 {
+		return tr.r.Read(p)
+	}
+	chunk := tr.rate / 10
+	if chunk < 1 {
+		chunk = 1
+	}
+	if len(p) > chunk {
+		p = p[:chunk]
+	}
+	n, err := tr.r.Read(p)
+	if !sleep(tr.ctx, time.Duration(n)*time.Second/time.Duration(tr.rate)) {
+		return n, tr.ctx.Err()
+	}
+	return n, err
+}
+
+// standardizePath cleans the provided path, ensuring it has a leading slash. Double slashes and '.' and '..' segments
+// are collapsed, and trailing slashes are removed, so "/path/" and "/path" are equivalent.
+func standardizePath(p string) string {
+	if len(p) == 0 {
 		return "/"
 	}
-	if path[0] == '/' {
-		return path
+	if p[0] != '/' {
+		p = fmt.Sprintf("/%s", p)
 	}
-	return fmt.Sprintf("/%s", path)
+	return path.Clean(p)
 }
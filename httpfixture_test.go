@@ -1,12 +1,39 @@
 package httpfixture_test
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/orkes-io/go-httpfixture"
 	"io"
+	"io/fs"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestFixture(t *testing.T) {
@@ -64,6 +91,51 @@ func TestFixture(t *testing.T) {
 			wantBody:  "hello world",
 			wantCode:  http.StatusOK,
 		},
+		{
+			name:      "GetOK trailing slash route",
+			reqMethod: http.MethodGet,
+			reqPath:   "/path",
+			reqBody:   nil,
+			fixture:   httpfixture.GetOK("/path/", "hello world"),
+			wantBody:  "hello world",
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "GetOK trailing slash request",
+			reqMethod: http.MethodGet,
+			reqPath:   "/path/",
+			reqBody:   nil,
+			fixture:   httpfixture.GetOK("/path", "hello world"),
+			wantBody:  "hello world",
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "GetOK unclean request path",
+			reqMethod: http.MethodGet,
+			reqPath:   "//other/../path/./subpath",
+			reqBody:   nil,
+			fixture:   httpfixture.GetOK("path/subpath", "hello world"),
+			wantBody:  "hello world",
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "GetOK root",
+			reqMethod: http.MethodGet,
+			reqPath:   "/",
+			reqBody:   nil,
+			fixture:   httpfixture.GetOK("/", "hello world"),
+			wantBody:  "hello world",
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "GetOK missing",
+			reqMethod: http.MethodGet,
+			reqPath:   "/",
+			reqBody:   nil,
+			fixture:   httpfixture.GetOK("/path", "hello world"),
+			wantBody:  "404 page not found\n",
+			wantCode:  http.StatusNotFound,
+		},
 		{
 			name:      "GetBytesOK",
 			reqMethod: http.MethodGet,
@@ -163,6 +235,150 @@ func TestFixture(t *testing.T) {
 	}
 }
 
+func TestFileMissing(t *testing.T) {
+	defer func() {
+		err, ok := recover().(error)
+		if !ok {
+			t.Fatalf("want error panic value; got: %v", err)
+		}
+		if !errors.Is(err, httpfixture.ErrFixtureFile) {
+			t.Fatalf("want error matching ErrFixtureFile; got: %v", err)
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("want error matching fs.ErrNotExist; got: %v", err)
+		}
+		if !strings.Contains(err.Error(), "testdata/missing.json") {
+			t.Fatalf("want error mentioning file path; got: %v", err)
+		}
+	}()
+	httpfixture.GetFileOK("/path", "testdata/missing.json")
+}
+
+func TestFileContentType(t *testing.T) {
+	s := httpfixture.NewServer(
+		httpfixture.GetFileOK("/json", "testdata/basic-body.json"),
+		httpfixture.GetFileOK("/png", "testdata/pixel.png"),
+		httpfixture.GetFileOK("/http", "testdata/example.http"),
+		httpfixture.GetFileOK("/override", "testdata/basic-body.json",
+			httpfixture.WithHeader("Content-Type", "application/vnd.example+json")),
+	)
+	s.Start(t)
+	defer s.Close()
+
+	tests := []struct {
+		path     string
+		wantType string
+	}{
+		{path: "/json", wantType: "application/json"},
+		{path: "/png", wantType: "image/png"},
+		{path: "/http", wantType: "text/plain; charset=utf-8"},
+		{path: "/override", wantType: "application/vnd.example+json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp, err := http.Get(s.URL() + tt.path)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if got := resp.Header.Values("Content-Type"); len(got) != 1 || got[0] != tt.wantType {
+				t.Fatalf("want Content-Type: %s; got: %v", tt.wantType, got)
+			}
+		})
+	}
+}
+
+func TestGzipFileOK(t *testing.T) {
+	want := must(os.ReadFile("testdata/basic-body.json"))
+	s := httpfixture.NewServer(httpfixture.GzipFileOK("/file", http.MethodGet, "testdata/basic-body.json"))
+	s.Start(t)
+	defer s.Close()
+
+	tests := []struct {
+		acceptEncoding string
+		wantGzip       bool
+	}{
+		{acceptEncoding: "gzip", wantGzip: true},
+		{acceptEncoding: "br;q=1.0, gzip;q=0.8", wantGzip: true},
+		{acceptEncoding: "identity"},
+		{acceptEncoding: "gzip;q=0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.acceptEncoding, func(t *testing.T) {
+			req := withHeader(must(http.NewRequest(http.MethodGet, s.URL()+"/file", nil)), "Accept-Encoding", tt.acceptEncoding)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+				t.Fatalf("want Content-Type: application/json; got: %s", ct)
+			}
+			body := must(io.ReadAll(resp.Body))
+			if !tt.wantGzip {
+				if ce := resp.Header.Get("Content-Encoding"); ce != "" {
+					t.Fatalf("want no Content-Encoding; got: %s", ce)
+				}
+				if !bytes.Equal(body, want) {
+					t.Fatalf("want body: '%s'; got: '%s'", want, body)
+				}
+				return
+			}
+			if ce := resp.Header.Get("Content-Encoding"); ce != "gzip" {
+				t.Fatalf("want Content-Encoding: gzip; got: %s", ce)
+			}
+			zr := must(gzip.NewReader(bytes.NewReader(body)))
+			if got := must(io.ReadAll(zr)); !bytes.Equal(got, want) {
+				t.Fatalf("want decompressed body: '%s'; got: '%s'", want, got)
+			}
+		})
+	}
+}
+
+func TestWithRangeSupport(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.GetBytesOK("/blob", []byte("0123456789abcdefghij"),
+		httpfixture.WithRangeSupport()))
+	s.Start(t)
+	defer s.Close()
+
+	tests := []struct {
+		rangeHeader      string
+		wantCode         int
+		wantBody         string
+		wantContentRange string
+	}{
+		{rangeHeader: "bytes=0-9", wantCode: http.StatusPartialContent, wantBody: "0123456789", wantContentRange: "bytes 0-9/20"},
+		{rangeHeader: "bytes=10-", wantCode: http.StatusPartialContent, wantBody: "abcdefghij", wantContentRange: "bytes 10-19/20"},
+		{rangeHeader: "bytes=-5", wantCode: http.StatusPartialContent, wantBody: "fghij", wantContentRange: "bytes 15-19/20"},
+		{rangeHeader: "bytes=15-99", wantCode: http.StatusPartialContent, wantBody: "fghij", wantContentRange: "bytes 15-19/20"},
+		{rangeHeader: "bytes=20-", wantCode: http.StatusRequestedRangeNotSatisfiable, wantContentRange: "bytes */20"},
+		{rangeHeader: "bytes=0-1,5-6", wantCode: http.StatusOK, wantBody: "0123456789abcdefghij"},
+		{rangeHeader: "", wantCode: http.StatusOK, wantBody: "0123456789abcdefghij"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rangeHeader, func(t *testing.T) {
+			req := must(http.NewRequest(http.MethodGet, s.URL()+"/blob", nil))
+			if tt.rangeHeader != "" {
+				req.Header.Set("Range", tt.rangeHeader)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("want statusCode: %d; got: %d", tt.wantCode, resp.StatusCode)
+			}
+			if body := string(must(io.ReadAll(resp.Body))); body != tt.wantBody {
+				t.Fatalf("want body: '%s'; got: '%s'", tt.wantBody, body)
+			}
+			if cr := resp.Header.Get("Content-Range"); cr != tt.wantContentRange {
+				t.Fatalf("want Content-Range: '%s'; got: '%s'", tt.wantContentRange, cr)
+			}
+			if ar := resp.Header.Get("Accept-Ranges"); ar != "bytes" {
+				t.Fatalf("want Accept-Ranges: bytes; got: '%s'", ar)
+			}
+		})
+	}
+}
+
 func TestSeq(t *testing.T) {
 	s := httpfixture.NewServer(httpfixture.Seq("/path", "GET",
 		httpfixture.OK("", "body1"),
@@ -195,93 +411,2568 @@ func TestSeq(t *testing.T) {
 	}
 }
 
-func TestFixtureAssertions(t *testing.T) {
+func TestSeqOpts(t *testing.T) {
+	mockT := &testing.T{}
+	s := httpfixture.NewServer(httpfixture.SeqOpts("/path", http.MethodGet,
+		[]httpfixture.F{httpfixture.OK("", "body1"), httpfixture.OK("", "body2")},
+		httpfixture.AssertHeaderMatches("Authorization", "Bearer token")))
+	s.Start(mockT)
+	defer s.Close()
+
+	for i, auth := range []string{"Bearer token", "Bearer token", ""} {
+		req := must(http.NewRequest(http.MethodGet, s.URL()+"/path", nil))
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		if _, err := http.DefaultClient.Do(req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if wantFailed := auth == ""; mockT.Failed() != wantFailed {
+			t.Fatalf("request %d: want failed: %t; got: %t", i, wantFailed, mockT.Failed())
+		}
+	}
+	if failures := s.AssertionFailures(); len(failures) != 1 {
+		t.Fatalf("want 1 assertion failure; got: %v", failures)
+	}
+}
+
+func TestSeqProgress(t *testing.T) {
+	seq := httpfixture.Seq("/path", http.MethodGet,
+		httpfixture.OK("", "body1"),
+		httpfixture.OK("", "body2"),
+		httpfixture.OK("", "body3"),
+	)
+	s := httpfixture.NewServer(seq)
+	s.Start(t)
+	defer s.Close()
+
+	p, ok := seq.(httpfixture.Progresser)
+	if !ok {
+		t.Fatalf("want Seq to implement Progresser")
+	}
+	for i := 0; i < 5; i++ {
+		if served, total := p.Progress(); served != i || total != 3 {
+			t.Fatalf("want progress: %d/3; got: %d/%d", i, served, total)
+		}
+		if _, err := http.Get(s.URL() + "/path"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestByPrefer(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.ByPrefer("/path", http.MethodPost,
+		map[string]httpfixture.F{
+			"return=minimal":        httpfixture.ResponseCode("", "", http.StatusNoContent),
+			"return=representation": httpfixture.Bytes("", "", http.StatusCreated, []byte(`{"id":1}`)),
+		},
+		httpfixture.Bytes("", "", http.StatusAccepted, []byte("default")),
+	))
+	s.Start(t)
+	defer s.Close()
+
 	tests := []struct {
-		name        string
-		req         *http.Request
-		fixture     httpfixture.F
-		wantFailure bool
+		prefer   string
+		wantCode int
+		wantBody string
 	}{
-		{
-			name: "AssertBodyContains",
-			req: must(http.NewRequest("GET", "http://localhost:8080/path",
-				bytes.NewBufferString("the quick brown fox jumped over the"))),
-			fixture: httpfixture.GetOK("/path", "",
-				httpfixture.AssertBodyContains("quick brown fox")),
-		},
-		{
-			name: "AssertBodyContains failure",
-			req: must(http.NewRequest("GET", "http://localhost:8080/path/another",
-				bytes.NewBufferString("something else even"))),
-			fixture: httpfixture.GetOK("/path/another", "response body",
-				httpfixture.AssertBodyContains("quick brown fox")),
-			wantFailure: true,
-		},
-		{
-			name: "AssertBodyContainsBytes",
-			req: must(http.NewRequest("GET", "http://localhost:8080/path",
-				bytes.NewBufferString("some text\n\n\rother text here"))),
-			fixture: httpfixture.GetOK("/path", "",
-				httpfixture.AssertBodyContainsBytes([]byte("\n\n\r"))),
-		},
-		{
-			name: "AssertBodyContainsBytes failure",
-			req: must(http.NewRequest("GET", "http://localhost:8080/path",
-				bytes.NewBufferString(""))),
-			fixture: httpfixture.GetOK("/path", "",
-				httpfixture.AssertBodyContainsBytes([]byte("o"))),
-			wantFailure: true,
-		},
-		{
-			name: "AssertHeaderMatches",
-			req: withHeader(must(http.NewRequest("GET", "http://localhost:7070/path", nil)),
-				"Content-Type", "application/json"),
-			fixture: httpfixture.GetOK("/path", "",
-				httpfixture.AssertHeaderMatches("Content-Type", "application/json")),
-		},
-		{
-			name: "AssertHeaderMatches failure",
-			req:  must(http.NewRequest("GET", "http://localhost:7070/path", nil)),
-			fixture: httpfixture.GetOK("/path", "",
-				httpfixture.AssertHeaderMatches("Content-Type", "application/json")),
-			wantFailure: true,
-		},
-		{
-			name: "AssertURLContains",
-			req:  must(http.NewRequest("GET", "http://localhost:7070/tasks/1234/status", nil)),
-			fixture: httpfixture.GetOK("/path", "",
-				httpfixture.AssertURLContains("tasks/1234/")),
-		},
-		{
-			name: "AssertURLContains",
-			req:  must(http.NewRequest("GET", "http://localhost:7070/tasks/1234/status", nil)),
-			fixture: httpfixture.GetOK("/path", "",
-				httpfixture.AssertURLContains("tasks/4567/")),
-			wantFailure: true,
+		{prefer: "return=minimal", wantCode: http.StatusNoContent},
+		{prefer: "return=representation", wantCode: http.StatusCreated, wantBody: `{"id":1}`},
+		{prefer: "respond-async, return=representation; foo=bar", wantCode: http.StatusCreated, wantBody: `{"id":1}`},
+		{prefer: "", wantCode: http.StatusAccepted, wantBody: "default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefer, func(t *testing.T) {
+			req := must(http.NewRequest(http.MethodPost, s.URL()+"/path", nil))
+			if tt.prefer != "" {
+				req.Header.Set("Prefer", tt.prefer)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("want statusCode: %d; got: %d", tt.wantCode, resp.StatusCode)
+			}
+			body := string(must(io.ReadAll(resp.Body)))
+			if body != tt.wantBody {
+				t.Fatalf("want body: '%s'; got: '%s'", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestByHeader(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.ByHeader("/config", http.MethodGet, "X-Tenant",
+		map[string]httpfixture.F{
+			"acme":    httpfixture.GetOK("", "acme config"),
+			"initech": httpfixture.GetOK("", "initech config"),
 		},
+		httpfixture.NotFound("", ""),
+	))
+	s.Start(t)
+	defer s.Close()
+
+	tests := []struct {
+		tenant   string
+		wantCode int
+		wantBody string
+	}{
+		{tenant: "acme", wantCode: http.StatusOK, wantBody: "acme config"},
+		{tenant: "initech", wantCode: http.StatusOK, wantBody: "initech config"},
+		{tenant: "umbrella", wantCode: http.StatusNotFound},
+		{tenant: "", wantCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tenant, func(t *testing.T) {
+			req := must(http.NewRequest(http.MethodGet, s.URL()+"/config", nil))
+			if tt.tenant != "" {
+				req.Header.Set("X-Tenant", tt.tenant)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("want statusCode: %d; got: %d", tt.wantCode, resp.StatusCode)
+			}
+			if body := string(must(io.ReadAll(resp.Body))); body != tt.wantBody {
+				t.Fatalf("want body: '%s'; got: '%s'", tt.wantBody, body)
+			}
+		})
 	}
+}
+
+func TestWebhookHMAC(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.WebhookHMAC("/hook", "s3cr3t", "X-Hub-Signature-256", "sha256=",
+		httpfixture.BytesOK("", http.MethodPost, []byte("ok"))))
+	s.Start(t)
+	defer s.Close()
+
+	body := []byte(`{"action":"opened"}`)
+	mac := hmac.New(sha256.New, []byte("s3cr3t"))
+	mac.Write(body)
+	validSig := "sha256=" + hex.EncodeToString(mac.Sum(nil))
 
+	tests := []struct {
+		name     string
+		sig      string
+		wantCode int
+	}{
+		{name: "valid signature", sig: validSig, wantCode: http.StatusOK},
+		{name: "invalid signature", sig: "sha256=" + hex.EncodeToString([]byte("nope")), wantCode: http.StatusUnauthorized},
+		{name: "missing prefix", sig: strings.TrimPrefix(validSig, "sha256="), wantCode: http.StatusUnauthorized},
+		{name: "missing signature", wantCode: http.StatusUnauthorized},
+	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			defer func() {
-				err := recover()
-				if err != nil && !tt.wantFailure {
-					t.Fatalf("caught panic in test: %v", err)
-				}
-			}()
-			testT := &testing.T{}
-			_ = tt.fixture.Run(testT, tt.req)
-
-			if tt.wantFailure != testT.Failed() {
-				t.Fatalf("unexpected failure reported; want: %t; got: %t", tt.wantFailure, testT.Failed())
+			req := must(http.NewRequest(http.MethodPost, s.URL()+"/hook", bytes.NewReader(body)))
+			if tt.sig != "" {
+				req.Header.Set("X-Hub-Signature-256", tt.sig)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("want statusCode: %d; got: %d", tt.wantCode, resp.StatusCode)
 			}
 		})
 	}
 }
 
-func withHeader(req *http.Request, key, value string) *http.Request {
-	req.Header.Add(key, value)
-	return req
+func TestWithDelayCanceled(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.GetOK("/slow", "too late", httpfixture.WithDelay(5*time.Second)))
+	s.Start(t)
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req := must(http.NewRequestWithContext(ctx, http.MethodGet, s.URL()+"/slow", nil))
+	start := time.Now()
+	_, err := http.DefaultClient.Do(req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want context.DeadlineExceeded; got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("request was not canceled promptly; took %s", elapsed)
+	}
+}
+
+func TestRequireHeaderValue(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.RequireHeaderValue("/path", http.MethodGet, "X-Api-Key", "secret",
+		httpfixture.GetOK("", "hello world")))
+	s.Start(t)
+	defer s.Close()
+
+	tests := []struct {
+		name     string
+		key      string
+		wantCode int
+		wantBody string
+	}{
+		{name: "matching", key: "secret", wantCode: http.StatusOK, wantBody: "hello world"},
+		{name: "not matching", key: "wrong", wantCode: http.StatusUnauthorized},
+		{name: "missing", wantCode: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := must(http.NewRequest(http.MethodGet, s.URL()+"/path", nil))
+			if tt.key != "" {
+				req.Header.Set("X-Api-Key", tt.key)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("want statusCode: %d; got: %d", tt.wantCode, resp.StatusCode)
+			}
+			if body := string(must(io.ReadAll(resp.Body))); body != tt.wantBody {
+				t.Fatalf("want body: '%s'; got: '%s'", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestCachedOK(t *testing.T) {
+	s := httpfixture.NewServer(
+		httpfixture.CachedOK("/static", "asset", "public, max-age=60"),
+		httpfixture.GetOK("/private", "secret", httpfixture.WithCacheControl("no-store")),
+	)
+	s.Start(t)
+	defer s.Close()
+
+	tests := []struct {
+		path      string
+		wantCache string
+		wantBody  string
+	}{
+		{path: "/static", wantCache: "public, max-age=60", wantBody: "asset"},
+		{path: "/private", wantCache: "no-store", wantBody: "secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp, err := http.Get(s.URL() + tt.path)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if cc := resp.Header.Get("Cache-Control"); cc != tt.wantCache {
+				t.Fatalf("want Cache-Control: '%s'; got: '%s'", tt.wantCache, cc)
+			}
+			if body := string(must(io.ReadAll(resp.Body))); body != tt.wantBody {
+				t.Fatalf("want body: '%s'; got: '%s'", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestCacheable(t *testing.T) {
+	modTime := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	s := httpfixture.NewServer(httpfixture.Cacheable("/doc", "content", "v1", modTime))
+	s.Start(t)
+	defer s.Close()
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		wantCode int
+		wantBody string
+	}{
+		{name: "unconditional", wantCode: http.StatusOK, wantBody: "content"},
+		{name: "If-None-Match matches", header: http.Header{"If-None-Match": {`"v0", "v1"`}}, wantCode: http.StatusNotModified},
+		{name: "If-None-Match weak match", header: http.Header{"If-None-Match": {`W/"v1"`}}, wantCode: http.StatusNotModified},
+		{name: "If-None-Match stale", header: http.Header{"If-None-Match": {`"v0"`}}, wantCode: http.StatusOK, wantBody: "content"},
+		{
+			name:     "If-Modified-Since current",
+			header:   http.Header{"If-Modified-Since": {modTime.Format(http.TimeFormat)}},
+			wantCode: http.StatusNotModified,
+		},
+		{
+			name:     "If-Modified-Since stale",
+			header:   http.Header{"If-Modified-Since": {modTime.Add(-time.Hour).Format(http.TimeFormat)}},
+			wantCode: http.StatusOK,
+			wantBody: "content",
+		},
+		{
+			name: "If-None-Match takes precedence",
+			header: http.Header{
+				"If-None-Match":     {`"v0"`},
+				"If-Modified-Since": {modTime.Format(http.TimeFormat)},
+			},
+			wantCode: http.StatusOK,
+			wantBody: "content",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := must(http.NewRequest(http.MethodGet, s.URL()+"/doc", nil))
+			req.Header = tt.header
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("want statusCode: %d; got: %d", tt.wantCode, resp.StatusCode)
+			}
+			if body := string(must(io.ReadAll(resp.Body))); body != tt.wantBody {
+				t.Fatalf("want body: '%s'; got: '%s'", tt.wantBody, body)
+			}
+			if etag := resp.Header.Get("ETag"); etag != `"v1"` {
+				t.Fatalf(`want ETag: "v1"; got: %s`, etag)
+			}
+			if lm := resp.Header.Get("Last-Modified"); lm != "Mon, 02 Jan 2023 03:04:05 GMT" {
+				t.Fatalf("want Last-Modified: Mon, 02 Jan 2023 03:04:05 GMT; got: %s", lm)
+			}
+		})
+	}
+}
+
+func TestLegalBlock(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.LegalBlock("/banned", http.MethodGet, "https://authority.example.org"))
+	s.Start(t)
+	defer s.Close()
+
+	resp, err := http.Get(s.URL() + "/banned")
+	if err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	if resp.StatusCode != http.StatusUnavailableForLegalReasons {
+		t.Fatalf("want statusCode: %d; got: %d", http.StatusUnavailableForLegalReasons, resp.StatusCode)
+	}
+	want := `<https://authority.example.org>; rel="blocked-by"`
+	if link := resp.Header.Get("Link"); link != want {
+		t.Fatalf("want Link: '%s'; got: '%s'", want, link)
+	}
+}
+
+func TestGatewayTimeout(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.GatewayTimeout("/upstream", http.MethodGet, 100*time.Millisecond))
+	s.Start(t)
+	defer s.Close()
+
+	start := time.Now()
+	resp, err := http.Get(s.URL() + "/upstream")
+	if err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("want response delayed by at least 100ms; took: %s", elapsed)
+	}
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Fatalf("want statusCode: %d; got: %d", http.StatusGatewayTimeout, resp.StatusCode)
+	}
+	if body := string(must(io.ReadAll(resp.Body))); body != "upstream request timed out" {
+		t.Fatalf("want body: 'upstream request timed out'; got: '%s'", body)
+	}
+}
+
+func TestJSONError(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixture  httpfixture.F
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "default fields",
+			fixture:  httpfixture.JSONError("/err", http.MethodGet, http.StatusNotFound, "not found"),
+			wantCode: http.StatusNotFound,
+			wantBody: `{"code":404,"error":"not found"}`,
+		},
+		{
+			name: "custom fields",
+			fixture: httpfixture.JSONError("/err", http.MethodGet, http.StatusConflict, "already exists",
+				httpfixture.WithErrorFields("message", "status")),
+			wantCode: http.StatusConflict,
+			wantBody: `{"message":"already exists","status":409}`,
+		},
+		{
+			name: "empty message field",
+			fixture: httpfixture.JSONError("/err", http.MethodGet, http.StatusBadRequest, "bad input",
+				httpfixture.WithErrorFields("", "detail")),
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"":"bad input","detail":400}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := httpfixture.NewServer(tt.fixture)
+			s.Start(t)
+			defer s.Close()
+
+			resp, err := http.Get(s.URL() + "/err")
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("want statusCode: %d; got: %d", tt.wantCode, resp.StatusCode)
+			}
+			if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+				t.Fatalf("want Content-Type: application/json; got: %s", ct)
+			}
+			if body := string(must(io.ReadAll(resp.Body))); body != tt.wantBody {
+				t.Fatalf("want body: '%s'; got: '%s'", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestCSRFProtected(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.CSRFProtected("/form", http.MethodPost, "X-CSRF-Token", "tok3n",
+		httpfixture.BytesOK("", "", []byte("submitted"))))
+	s.Start(t)
+	defer s.Close()
+
+	tests := []struct {
+		name     string
+		token    string
+		wantCode int
+		wantBody string
+	}{
+		{name: "valid", token: "tok3n", wantCode: http.StatusOK, wantBody: "submitted"},
+		{name: "invalid", token: "forged", wantCode: http.StatusForbidden},
+		{name: "missing", wantCode: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := must(http.NewRequest(http.MethodPost, s.URL()+"/form", nil))
+			if tt.token != "" {
+				req.Header.Set("X-CSRF-Token", tt.token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("want statusCode: %d; got: %d", tt.wantCode, resp.StatusCode)
+			}
+			if body := string(must(io.ReadAll(resp.Body))); body != tt.wantBody {
+				t.Fatalf("want body: '%s'; got: '%s'", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestServerAdd(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.GetOK("/first", "first"))
+	s.Start(t)
+	defer s.Close()
+
+	s.Add(httpfixture.GetOK("/second", "second"))
+	for _, route := range []string{"/first", "/second"} {
+		resp, err := http.Get(s.URL() + route)
+		if err != nil {
+			t.Fatalf("error making request: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: want statusCode: %d; got: %d", route, http.StatusOK, resp.StatusCode)
+		}
+		if body, want := string(must(io.ReadAll(resp.Body))), route[1:]; body != want {
+			t.Fatalf("want body: '%s'; got: '%s'", want, body)
+		}
+	}
+	s.AssertTimes(t, "/second", http.MethodGet, 1)
+}
+
+func TestSetUnmatchedResponse(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.GetOK("/known", "known"))
+	s.SetUnmatchedResponse(http.StatusNotFound, []byte(`{"error":"no route"}`))
+	s.Start(t)
+	defer s.Close()
+
+	tests := []struct {
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{path: "/known", wantCode: http.StatusOK, wantBody: "known"},
+		{path: "/unknown", wantCode: http.StatusNotFound, wantBody: `{"error":"no route"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp, err := http.Get(s.URL() + tt.path)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("want statusCode: %d; got: %d", tt.wantCode, resp.StatusCode)
+			}
+			if body := string(must(io.ReadAll(resp.Body))); body != tt.wantBody {
+				t.Fatalf("want body: '%s'; got: '%s'", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestUnmatchedLogged(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		wantLog string
+	}{
+		{name: "unknown path", method: http.MethodGet, path: "/unknown", wantLog: "no fixture matched GET /unknown"},
+		{name: "wrong method", method: http.MethodDelete, path: "/known", wantLog: "no fixture matched DELETE /known"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &logRecorder{TB: t}
+			s := httpfixture.NewServer(httpfixture.GetOK("/known", "known"))
+			s.Start(rec)
+			defer s.Close()
+
+			req := must(http.NewRequest(tt.method, s.URL()+tt.path, nil))
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusNotFound {
+				t.Fatalf("want statusCode: %d; got: %d", http.StatusNotFound, resp.StatusCode)
+			}
+			logs := rec.String()
+			if !strings.Contains(logs, tt.wantLog) {
+				t.Fatalf("want log containing '%s'; got: '%s'", tt.wantLog, logs)
+			}
+			if !strings.Contains(logs, "GET /known") {
+				t.Fatalf("want log listing registered route 'GET /known'; got: '%s'", logs)
+			}
+		})
+	}
+}
+
+func TestOnRequest(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.GetOK("/data", "secret"))
+	s.OnRequest(func(rw http.ResponseWriter, req *http.Request) bool {
+		if req.Header.Get("Authorization") != "Bearer token" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return true
+		}
+		return false
+	})
+	s.Start(t)
+	defer s.Close()
+
+	tests := []struct {
+		name     string
+		auth     string
+		wantCode int
+		wantBody string
+	}{
+		{name: "authorized", auth: "Bearer token", wantCode: http.StatusOK, wantBody: "secret"},
+		{name: "unauthorized", auth: "Bearer forged", wantCode: http.StatusUnauthorized},
+		{name: "anonymous", wantCode: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := must(http.NewRequest(http.MethodGet, s.URL()+"/data", nil))
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("want statusCode: %d; got: %d", tt.wantCode, resp.StatusCode)
+			}
+			if body := string(must(io.ReadAll(resp.Body))); body != tt.wantBody {
+				t.Fatalf("want body: '%s'; got: '%s'", tt.wantBody, body)
+			}
+		})
+	}
+	s.AssertTimes(t, "/data", http.MethodGet, 1)
+}
+
+func TestAssertEach(t *testing.T) {
+	mockT := &testing.T{}
+	s := httpfixture.NewServer(httpfixture.GetOK("/a", "a"), httpfixture.GetOK("/b", "b"))
+	s.AssertEach(func(req *http.Request) error {
+		if req.Header.Get("X-API-Key") == "" {
+			return errors.New("missing X-API-Key header")
+		}
+		return nil
+	})
+	s.Start(mockT)
+	defer s.Close()
+
+	req := withHeader(must(http.NewRequest(http.MethodGet, s.URL()+"/a", nil)), "X-API-Key", "key")
+	if _, err := http.DefaultClient.Do(req); err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	if mockT.Failed() {
+		t.Fatalf("want request with API key to pass")
+	}
+	if _, err := http.Get(s.URL() + "/b"); err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	if !mockT.Failed() {
+		t.Fatalf("want request without API key to fail")
+	}
+	want := []string{"GET /b: missing X-API-Key header"}
+	if got := s.AssertionFailures(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("want failures: %v; got: %v", want, got)
+	}
+}
+
+func TestSetDefault(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.GetOK("/known", "known"))
+	s.SetDefault(httpfixture.Bytes("/ignored", http.MethodPost, http.StatusInternalServerError, []byte("fallback")))
+	s.Start(t)
+	defer s.Close()
+
+	tests := []struct {
+		method   string
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{method: http.MethodGet, path: "/known", wantCode: http.StatusOK, wantBody: "known"},
+		{method: http.MethodGet, path: "/unknown", wantCode: http.StatusInternalServerError, wantBody: "fallback"},
+		{method: http.MethodPut, path: "/known", wantCode: http.StatusInternalServerError, wantBody: "fallback"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+tt.path, func(t *testing.T) {
+			resp, err := http.DefaultClient.Do(must(http.NewRequest(tt.method, s.URL()+tt.path, nil)))
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("want statusCode: %d; got: %d", tt.wantCode, resp.StatusCode)
+			}
+			if body := string(must(io.ReadAll(resp.Body))); body != tt.wantBody {
+				t.Fatalf("want body: '%s'; got: '%s'", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestFixtures(t *testing.T) {
+	fixtures := []httpfixture.F{
+		httpfixture.GetOK("/a", "a"),
+		httpfixture.NotFound("/b", http.MethodDelete),
+		httpfixture.OK("/c", "c"),
+	}
+	s := httpfixture.NewServer(fixtures...)
+
+	got := s.Fixtures()
+	if len(got) != len(fixtures) {
+		t.Fatalf("want %d fixtures; got: %d", len(fixtures), len(got))
+	}
+	for i := range fixtures {
+		if got[i] != fixtures[i] {
+			t.Fatalf("fixture %d: want %s %s; got: %s %s", i,
+				fixtures[i].Method(), fixtures[i].Route(), got[i].Method(), got[i].Route())
+		}
+	}
+	got[0] = nil
+	if s.Fixtures()[0] != fixtures[0] {
+		t.Fatalf("modifying returned fixtures modified the server")
+	}
+}
+
+func TestTimes(t *testing.T) {
+	s := httpfixture.NewServer(
+		httpfixture.GetOK("/cached", "hello"),
+		httpfixture.GetOK("/other", "world"),
+	)
+	s.Start(t)
+	defer s.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Get(s.URL() + "/cached/item")
+			if err != nil {
+				t.Errorf("error making request: %v", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	s.AssertTimes(t, "/cached", http.MethodGet, 10)
+	s.AssertTimes(t, "/other", http.MethodGet, 0)
+	if got := s.Times("/cached", http.MethodPost); got != 0 {
+		t.Fatalf("want 0 calls for POST /cached; got: %d", got)
+	}
+
+	testT := &testing.T{}
+	s.AssertTimes(testT, "/cached", http.MethodGet, 1)
+	if !testT.Failed() {
+		t.Fatalf("expected AssertTimes to fail")
+	}
+}
+
+func TestCallOrder(t *testing.T) {
+	s := httpfixture.NewServer(
+		httpfixture.OK("/auth", "token"),
+		httpfixture.GetOK("/users", "users"),
+		httpfixture.GetOK("/orders", "orders"),
+	)
+	s.Start(t)
+	defer s.Close()
+
+	requests := []struct {
+		method string
+		path   string
+	}{
+		{method: http.MethodPost, path: "/auth"},
+		{method: http.MethodGet, path: "/users/1"},
+		{method: http.MethodGet, path: "/missing"},
+		{method: http.MethodGet, path: "/orders"},
+	}
+	for _, r := range requests {
+		resp, err := http.DefaultClient.Do(must(http.NewRequest(r.method, s.URL()+r.path, nil)))
+		if err != nil {
+			t.Fatalf("error making request: %v", err)
+		}
+		resp.Body.Close()
+	}
+	want := []string{"POST /auth", "GET /users", "GET /orders"}
+	if got := s.CallOrder(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("want call order: %v; got: %v", want, got)
+	}
+}
+
+func TestAssertEqualCalls(t *testing.T) {
+	s := httpfixture.NewServer(
+		httpfixture.OK("/primary", "primary"),
+		httpfixture.OK("/mirror", "mirror"),
+	)
+	s.Start(t)
+	defer s.Close()
+
+	for _, route := range []string{"/primary", "/mirror", "/primary"} {
+		resp, err := http.Post(s.URL()+route, "text/plain", strings.NewReader("payload"))
+		if err != nil {
+			t.Fatalf("error making request: %v", err)
+		}
+		resp.Body.Close()
+	}
+	testT := &testing.T{}
+	s.AssertEqualCalls(testT, "/primary", "/mirror")
+	if !testT.Failed() {
+		t.Fatalf("expected AssertEqualCalls to fail")
+	}
+
+	if _, err := http.Get(s.URL() + "/mirror"); err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	s.AssertEqualCalls(t, "/primary", "/mirror")
+}
+
+func TestAssertBodyStreaming(t *testing.T) {
+	var body bytes.Buffer
+	for i := 0; i < 100000; i++ {
+		fmt.Fprintf(&body, "line %d\n", i)
+	}
+	countLines := func(want int) func(r io.Reader) error {
+		return func(r io.Reader) error {
+			scanner := bufio.NewScanner(r)
+			var count int
+			for scanner.Scan() {
+				if !strings.HasPrefix(scanner.Text(), "line ") {
+					return fmt.Errorf("unexpected line: %s", scanner.Text())
+				}
+				count++
+			}
+			if count != want {
+				return fmt.Errorf("want %d lines; got: %d", want, count)
+			}
+			return scanner.Err()
+		}
+	}
+	tests := []struct {
+		name        string
+		fixture     httpfixture.F
+		wantFailure bool
+	}{
+		{
+			name: "all lines",
+			fixture: httpfixture.OK("/upload", "",
+				httpfixture.AssertBodyStreaming(countLines(100000)),
+				httpfixture.AssertBodyContains("line 99999\n")),
+		},
+		{
+			name: "wrong line count",
+			fixture: httpfixture.OK("/upload", "",
+				httpfixture.AssertBodyStreaming(countLines(10))),
+			wantFailure: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testT := &testing.T{}
+			req := must(http.NewRequest(http.MethodPost, "http://localhost:8080/upload", bytes.NewReader(body.Bytes())))
+			_ = tt.fixture.Run(testT, req)
+			if tt.wantFailure != testT.Failed() {
+				t.Fatalf("unexpected failure reported; want: %t; got: %t", tt.wantFailure, testT.Failed())
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.GetOK("/users", "fixture users"))
+	mux := http.NewServeMux()
+	mux.Handle("/fixtures/", http.StripPrefix("/fixtures", s.Handler(t)))
+	mux.HandleFunc("/real", func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(rw, "real handler")
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tests := []struct {
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{path: "/fixtures/users", wantCode: http.StatusOK, wantBody: "fixture users"},
+		{path: "/real", wantCode: http.StatusOK, wantBody: "real handler"},
+		{path: "/users", wantCode: http.StatusNotFound, wantBody: "404 page not found\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + tt.path)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("want statusCode: %d; got: %d", tt.wantCode, resp.StatusCode)
+			}
+			if body := string(must(io.ReadAll(resp.Body))); body != tt.wantBody {
+				t.Fatalf("want body: '%s'; got: '%s'", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestServerClient(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.GetOK("/path", "hello", httpfixture.MatchHost("any-host")))
+	s.StartTLS(t)
+	defer s.Close()
+
+	resp, err := s.Client().Get("http://any-host/path")
+	if err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want statusCode: %d; got: %d", http.StatusOK, resp.StatusCode)
+	}
+	if body := string(must(io.ReadAll(resp.Body))); body != "hello" {
+		t.Fatalf("want body: 'hello'; got: '%s'", body)
+	}
+}
+
+func TestAssertTLSVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		name        string
+		atLeast     uint16
+		wantFailure bool
+	}{
+		{name: "TLS 1.2 required", atLeast: tls.VersionTLS12},
+		{name: "TLS 1.3 required", atLeast: tls.VersionTLS13, wantFailure: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockT := &testing.T{}
+			s := httpfixture.NewServer(httpfixture.GetOK("/path", "", httpfixture.AssertTLSVersionAtLeast(tt.atLeast)))
+			s.StartTLS(mockT)
+			defer s.Close()
+
+			transport := s.Server.Client().Transport.(*http.Transport).Clone()
+			transport.TLSClientConfig.MaxVersion = tls.VersionTLS12
+			client := &http.Client{Transport: transport}
+			if _, err := client.Get(s.URL() + "/path"); err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if mockT.Failed() != tt.wantFailure {
+				t.Fatalf("want failure: %t; got: %t", tt.wantFailure, mockT.Failed())
+			}
+		})
+	}
+}
+
+func TestWithEarlyHints(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.GetOK("/page", "page",
+		httpfixture.WithEarlyHints(http.Header{"Link": {"</style.css>; rel=preload; as=style"}})))
+	s.Start(t)
+	defer s.Close()
+
+	var (
+		interimCodes []int
+		interimLinks []string
+	)
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			interimCodes = append(interimCodes, code)
+			interimLinks = append(interimLinks, header.Get("Link"))
+			return nil
+		},
+	}
+	req := must(http.NewRequest(http.MethodGet, s.URL()+"/page", nil))
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	if !reflect.DeepEqual(interimCodes, []int{http.StatusEarlyHints}) {
+		t.Fatalf("want interim responses: [103]; got: %v", interimCodes)
+	}
+	if want := "</style.css>; rel=preload; as=style"; interimLinks[0] != want {
+		t.Fatalf("want early hint Link: '%s'; got: '%s'", want, interimLinks[0])
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want statusCode: %d; got: %d", http.StatusOK, resp.StatusCode)
+	}
+	if link := resp.Header.Get("Link"); link != "" {
+		t.Fatalf("want no Link header in final response; got: '%s'", link)
+	}
+}
+
+func TestStartHTTP2(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.GetOK("/path", "hello h2"))
+	s.StartHTTP2(t)
+	defer s.Close()
+
+	resp, err := s.Client().Get(s.URL() + "/path")
+	if err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("want HTTP/2; got: %s", resp.Proto)
+	}
+	if body := string(must(io.ReadAll(resp.Body))); body != "hello h2" {
+		t.Fatalf("want body: 'hello h2'; got: '%s'", body)
+	}
+}
+
+func TestWaitForRequests(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.OK("/telemetry", ""))
+	s.Start(t)
+	defer s.Close()
+
+	for i := 0; i < 3; i++ {
+		go func(i int) {
+			resp, err := http.Post(s.URL()+"/telemetry", "text/plain", strings.NewReader(fmt.Sprintf("event %d", i)))
+			if err == nil {
+				resp.Body.Close()
+			}
+		}(i)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.WaitForRequests(ctx, 3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reqs := s.Requests()
+	if len(reqs) != 3 {
+		t.Fatalf("want 3 requests; got: %d", len(reqs))
+	}
+	for _, req := range reqs {
+		if body := string(must(io.ReadAll(req.Body))); !strings.HasPrefix(body, "event ") {
+			t.Fatalf("unexpected body recorded: '%s'", body)
+		}
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := s.WaitForRequests(ctx, 4)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "only 3 arrived") {
+		t.Fatalf("want deadline exceeded error describing 3 requests; got: %v", err)
+	}
+}
+
+func TestAssertNotCalled(t *testing.T) {
+	s := httpfixture.NewServer(
+		httpfixture.GetOK("/called", "hello"),
+		httpfixture.GetOK("/untouched", "world"),
+	)
+	s.Start(t)
+	defer s.Close()
+
+	if _, err := http.Get(s.URL() + "/called"); err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+
+	s.AssertNotCalled(t, "/untouched", http.MethodGet)
+	testT := &testing.T{}
+	s.AssertNotCalled(testT, "/called", http.MethodGet)
+	if !testT.Failed() {
+		t.Fatalf("expected AssertNotCalled to fail for a called route")
+	}
+}
+
+func TestServerNotStarted(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.GetOK("/path", "hello world"))
+	defer s.Close()
+
+	t.Run("URL", func(t *testing.T) {
+		defer func() {
+			err, ok := recover().(error)
+			if !ok || !errors.Is(err, httpfixture.ErrNotStarted) {
+				t.Fatalf("want panic matching ErrNotStarted; got: %v", err)
+			}
+		}()
+		_ = s.URL()
+	})
+	t.Run("ServeHTTP", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/path", nil))
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("want statusCode: %d; got: %d", http.StatusInternalServerError, rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), "server not started") {
+			t.Fatalf("want body mentioning server not started; got: '%s'", rec.Body.String())
+		}
+	})
+}
+
+func TestSlowReader(t *testing.T) {
+	// A read rate of 1000 bytes per second reads at most 100 bytes every 100ms.
+	f := httpfixture.SlowReader("/upload", http.MethodPut, http.StatusAccepted, 1000)
+
+	tests := []struct {
+		name      string
+		cancel    bool
+		wantResp  bool
+		wantBytes int
+	}{
+		{name: "reads whole body", wantResp: true, wantBytes: 500},
+		{name: "stops reading when client goes away", cancel: true, wantBytes: 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+			body := &readRecorder{r: bytes.NewReader(bytes.Repeat([]byte("a"), 500))}
+			resp := f.Run(t, httptest.NewRequest(http.MethodPut, "/upload", body).WithContext(ctx))
+
+			if !tt.wantResp && resp != nil {
+				t.Fatalf("want nil response; got statusCode: %d", resp.StatusCode)
+			}
+			if tt.wantResp && (resp == nil || resp.StatusCode != http.StatusAccepted) {
+				t.Fatalf("want statusCode: %d; got response: %v", http.StatusAccepted, resp)
+			}
+			if body.total != tt.wantBytes {
+				t.Fatalf("want %d bytes read; got: %d", tt.wantBytes, body.total)
+			}
+			for i, n := range body.sizes {
+				if n > 100 {
+					t.Fatalf("want reads of at most 100 bytes; read %d got %d bytes", i, n)
+				}
+			}
+		})
+	}
+}
+
+// readRecorder is an io.Reader which records the size of each read from r.
+type readRecorder struct {
+	r     io.Reader
+	sizes []int
+	total int
+}
+
+func (rr *readRecorder) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	rr.sizes = append(rr.sizes, n)
+	rr.total += n
+	return n, err
+}
+
+func TestTemplate(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.Template("/path", http.MethodGet, http.StatusOK,
+		`{"correlationId":"{{.Header.Get "X-Correlation-Id"}}","path":"{{.URL.Path}}"}`))
+	s.Start(t)
+	defer s.Close()
+
+	for _, id := range []string{"abc", "def"} {
+		req := must(http.NewRequest(http.MethodGet, s.URL()+"/path/"+id, nil))
+		req.Header.Set("X-Correlation-Id", id)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error making request: %v", err)
+		}
+		want := fmt.Sprintf(`{"correlationId":"%s","path":"/path/%s"}`, id, id)
+		if body := string(must(io.ReadAll(resp.Body))); body != want {
+			t.Fatalf("want body: '%s'; got: '%s'", want, body)
+		}
+	}
+}
+
+func TestTemplateInvalid(t *testing.T) {
+	defer func() {
+		if err := recover(); err == nil {
+			t.Fatalf("expected panic for invalid template")
+		}
+	}()
+	httpfixture.Template("/path", http.MethodGet, http.StatusOK, "{{.Header.Get")
+}
+
+func TestWithBandwidthLimit(t *testing.T) {
+	body := bytes.Repeat([]byte("a"), 2000)
+	s := httpfixture.NewServer(httpfixture.GetBytesOK("/download", body, httpfixture.WithBandwidthLimit(4000)))
+	s.Start(t)
+	defer s.Close()
+
+	start := time.Now()
+	resp, err := http.Get(s.URL() + "/download")
+	if err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	got := must(io.ReadAll(resp.Body))
+	elapsed := time.Since(start)
+	if !bytes.Equal(got, body) {
+		t.Fatalf("want %d byte body; got %d bytes", len(body), len(got))
+	}
+	if elapsed < 400*time.Millisecond || elapsed > 2*time.Second {
+		t.Fatalf("want download to take roughly 500ms; took: %v", elapsed)
+	}
+}
+
+func TestHead(t *testing.T) {
+	mockT := &testing.T{}
+	s := httpfixture.NewServer(httpfixture.OK("/path", "hello",
+		httpfixture.WithSetCookie(&http.Cookie{Name: "session", Value: "abc123"})))
+	s.Start(mockT)
+	defer s.Close()
+
+	resp, err := http.Head(s.URL() + "/path")
+	if err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want statusCode: %d; got: %d", http.StatusOK, resp.StatusCode)
+	}
+	if cl := resp.Header.Get("Content-Length"); cl != "5" {
+		t.Fatalf("want Content-Length: 5; got: %q", cl)
+	}
+	if got := resp.Header.Get("Set-Cookie"); got != "session=abc123" {
+		t.Fatalf("want Set-Cookie header: session=abc123; got: %q", got)
+	}
+	if body := must(io.ReadAll(resp.Body)); len(body) != 0 {
+		t.Fatalf("want empty body; got: %q", body)
+	}
+	if mockT.Failed() {
+		t.Fatalf("want HEAD request to be served without failure")
+	}
+}
+
+func TestAssertContentLength(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        io.Reader
+		opt         httpfixture.FixtureOpt
+		wantFailure bool
+	}{
+		{name: "exact length", body: strings.NewReader("hello"), opt: httpfixture.AssertContentLength(5)},
+		{name: "wrong length", body: strings.NewReader("hello!"), opt: httpfixture.AssertContentLength(5), wantFailure: true},
+		{name: "chunked length", body: io.MultiReader(strings.NewReader("hello")), opt: httpfixture.AssertContentLength(5),
+			wantFailure: true},
+		{name: "chunked", body: io.MultiReader(strings.NewReader("hello")), opt: httpfixture.AssertChunked()},
+		{name: "not chunked", body: strings.NewReader("hello"), opt: httpfixture.AssertChunked(), wantFailure: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockT := &testing.T{}
+			s := httpfixture.NewServer(httpfixture.BytesOK("/upload", http.MethodPut, nil, tt.opt))
+			s.Start(mockT)
+			defer s.Close()
+
+			// the client only knows the length of some readers, such as *strings.Reader; others are sent chunked.
+			req := must(http.NewRequest(http.MethodPut, s.URL()+"/upload", tt.body))
+			if _, err := http.DefaultClient.Do(req); err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if mockT.Failed() != tt.wantFailure {
+				t.Fatalf("want failure: %t; got: %t", tt.wantFailure, mockT.Failed())
+			}
+		})
+	}
+}
+
+func TestWithChunked(t *testing.T) {
+	body := bytes.Repeat([]byte("0123456789"), 300)
+	tests := []struct {
+		name     string
+		fixture  httpfixture.F
+		wantBody []byte
+	}{
+		{
+			name:     "Bytes",
+			fixture:  httpfixture.GetBytesOK("/path", body, httpfixture.WithChunked()),
+			wantBody: body,
+		},
+		{
+			name:     "Reader",
+			fixture:  httpfixture.Reader("/path", http.MethodGet, http.StatusOK, bytes.NewReader(body), httpfixture.WithChunked()),
+			wantBody: body,
+		},
+		{
+			name:     "ChunkedOK",
+			fixture:  httpfixture.ChunkedOK("/path", http.MethodGet, body),
+			wantBody: body,
+		},
+		{
+			name:    "empty body",
+			fixture: httpfixture.ResponseCode("/path", http.MethodGet, http.StatusOK, httpfixture.WithChunked()),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := httpfixture.NewServer(tt.fixture)
+			s.Start(t)
+			defer s.Close()
+
+			resp, err := http.Get(s.URL() + "/path")
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if len(resp.TransferEncoding) != 1 || resp.TransferEncoding[0] != "chunked" {
+				t.Fatalf("want chunked transfer encoding; got: %v", resp.TransferEncoding)
+			}
+			if resp.ContentLength != -1 {
+				t.Fatalf("want unknown content length; got: %d", resp.ContentLength)
+			}
+			got := must(io.ReadAll(resp.Body))
+			if !bytes.Equal(got, tt.wantBody) {
+				t.Fatalf("response body did not match; got %d bytes", len(got))
+			}
+		})
+	}
+}
+
+func TestWithSetCookie(t *testing.T) {
+	s := httpfixture.NewServer(
+		httpfixture.BytesOK("/login", http.MethodPost, nil,
+			httpfixture.WithSetCookie(&http.Cookie{Name: "session", Value: "abc123", Path: "/"}),
+			httpfixture.WithSetCookie(&http.Cookie{Name: "theme", Value: "dark"})),
+		httpfixture.GetOK("/profile", "gopher", httpfixture.AssertCookie("session", "abc123")),
+	)
+	s.Start(t)
+	defer s.Close()
+
+	resp, err := http.Post(s.URL()+"/login", "text/plain", nil)
+	if err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	cookies := resp.Cookies()
+	if len(cookies) != 2 || cookies[0].Name != "session" || cookies[0].Value != "abc123" || cookies[1].Name != "theme" {
+		t.Fatalf("unexpected cookies: %v", cookies)
+	}
+
+	req := must(http.NewRequest(http.MethodGet, s.URL()+"/profile", nil))
+	req.AddCookie(cookies[0])
+	if _, err := http.DefaultClient.Do(req); err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+}
+
+func TestRouteSegmentBoundary(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.GetOK("/users/", "users"))
+	s.Start(t)
+	defer s.Close()
+
+	tests := []struct {
+		path     string
+		wantCode int
+	}{
+		{path: "/users", wantCode: http.StatusOK},
+		{path: "/users/", wantCode: http.StatusOK},
+		{path: "/users/1", wantCode: http.StatusOK},
+		{path: "/usersettings", wantCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp, err := http.Get(s.URL() + tt.path)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("want statusCode: %d; got: %d", tt.wantCode, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestRouteSpecificity(t *testing.T) {
+	broad := httpfixture.OK("/", "root")
+	specific := httpfixture.OK("/api/users", "users")
+	wildcard := httpfixture.OK("/api", "any method")
+	exact := httpfixture.GetOK("/api", "get")
+	tests := []struct {
+		name     string
+		fixtures []httpfixture.F
+		path     string
+		wantBody string
+	}{
+		{name: "specific registered last", fixtures: []httpfixture.F{broad, specific}, path: "/api/users/1", wantBody: "users"},
+		{name: "specific registered first", fixtures: []httpfixture.F{specific, broad}, path: "/api/users/1", wantBody: "users"},
+		{name: "broad fallback", fixtures: []httpfixture.F{specific, broad}, path: "/other", wantBody: "root"},
+		{name: "exact method registered last", fixtures: []httpfixture.F{wildcard, exact}, path: "/api", wantBody: "get"},
+		{name: "exact method registered first", fixtures: []httpfixture.F{exact, wildcard}, path: "/api", wantBody: "get"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := httpfixture.NewServer(tt.fixtures...)
+			s.Start(t)
+			defer s.Close()
+
+			resp, err := http.Get(s.URL() + tt.path)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if body := string(must(io.ReadAll(resp.Body))); body != tt.wantBody {
+				t.Fatalf("want body: '%s'; got: '%s'", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestMethodCaseInsensitive(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.BytesOK("/items", "post", []byte("created")))
+	s.Start(t)
+	defer s.Close()
+
+	for _, method := range []string{http.MethodPost, "post"} {
+		t.Run(method, func(t *testing.T) {
+			resp, err := http.DefaultClient.Do(must(http.NewRequest(method, s.URL()+"/items", nil)))
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("want statusCode: %d; got: %d", http.StatusOK, resp.StatusCode)
+			}
+			if body := string(must(io.ReadAll(resp.Body))); body != "created" {
+				t.Fatalf("want body: 'created'; got: '%s'", body)
+			}
+		})
+	}
+	s.AssertTimes(t, "/items", "post", 2)
+	if got := s.Fixtures()[0].Method(); got != http.MethodPost {
+		t.Fatalf("want normalized method: %s; got: %s", http.MethodPost, got)
+	}
+}
+
+func TestMatchQuery(t *testing.T) {
+	s := httpfixture.NewServer(
+		httpfixture.GetOK("/items", "any"),
+		httpfixture.GetOK("/items", "type a", httpfixture.MatchQuery("type", "a")),
+		httpfixture.GetOK("/items", "type b", httpfixture.MatchQuery("type", "b")),
+		httpfixture.GetOK("/items", "type b, page 2", httpfixture.MatchQuery("type", "b"), httpfixture.MatchQuery("page", "2")),
+	)
+	s.Start(t)
+	defer s.Close()
+
+	tests := []struct {
+		query    string
+		wantBody string
+	}{
+		{query: "?type=a", wantBody: "type a"},
+		{query: "?type=b", wantBody: "type b"},
+		{query: "?page=2&type=b", wantBody: "type b, page 2"},
+		{query: "?type=c", wantBody: "any"},
+		{query: "", wantBody: "any"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			resp, err := http.Get(s.URL() + "/items" + tt.query)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if body := string(must(io.ReadAll(resp.Body))); body != tt.wantBody {
+				t.Fatalf("want body: '%s'; got: '%s'", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestMatchQueryOverExactMethod(t *testing.T) {
+	for _, name := range []string{"constrained first", "constrained last"} {
+		t.Run(name, func(t *testing.T) {
+			fixtures := []httpfixture.F{
+				httpfixture.OK("/items", "type a", httpfixture.MatchQuery("type", "a")),
+				httpfixture.GetOK("/items", "any"),
+			}
+			if name == "constrained last" {
+				fixtures[0], fixtures[1] = fixtures[1], fixtures[0]
+			}
+			s := httpfixture.NewServer(fixtures...)
+			s.Start(t)
+			defer s.Close()
+
+			resp, err := http.Get(s.URL() + "/items?type=a")
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if body := string(must(io.ReadAll(resp.Body))); body != "type a" {
+				t.Fatalf("want body: 'type a'; got: '%s'", body)
+			}
+		})
+	}
+}
+
+func TestMatchHeaderAbsent(t *testing.T) {
+	s := httpfixture.NewServer(
+		httpfixture.GetOK("/profile", "authenticated"),
+		httpfixture.Bytes("/profile", http.MethodGet, http.StatusUnauthorized, []byte("anonymous"),
+			httpfixture.MatchHeaderAbsent("Authorization")),
+	)
+	s.Start(t)
+	defer s.Close()
+
+	tests := []struct {
+		name     string
+		auth     string
+		wantCode int
+		wantBody string
+	}{
+		{name: "with header", auth: "Bearer token", wantCode: http.StatusOK, wantBody: "authenticated"},
+		{name: "without header", wantCode: http.StatusUnauthorized, wantBody: "anonymous"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := must(http.NewRequest(http.MethodGet, s.URL()+"/profile", nil))
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("want statusCode: %d; got: %d", tt.wantCode, resp.StatusCode)
+			}
+			if body := string(must(io.ReadAll(resp.Body))); body != tt.wantBody {
+				t.Fatalf("want body: '%s'; got: '%s'", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestMaxCalls(t *testing.T) {
+	mockT := &testing.T{}
+	s := httpfixture.NewServer(httpfixture.ResponseCode("/flaky", http.MethodGet, http.StatusServiceUnavailable,
+		httpfixture.MaxCalls(3)))
+	s.Start(mockT)
+	defer s.Close()
+
+	for attempt := 1; attempt <= 4; attempt++ {
+		if _, err := http.Get(s.URL() + "/flaky"); err != nil {
+			t.Fatalf("error making request: %v", err)
+		}
+		if wantFailed := attempt > 3; mockT.Failed() != wantFailed {
+			t.Fatalf("attempt %d: want failed: %t; got: %t", attempt, wantFailed, mockT.Failed())
+		}
+	}
+}
+
+func TestAssertionFailures(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.GetOK("/path", "", httpfixture.AssertHeaderMatches("X-Api-Key", "secret")))
+	testT := &testing.T{}
+	s.Start(testT)
+	defer s.Close()
+
+	req := must(http.NewRequest(http.MethodGet, s.URL()+"/path", nil))
+	if _, err := http.DefaultClient.Do(req); err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	req.Header.Set("X-Api-Key", "secret")
+	if _, err := http.DefaultClient.Do(req); err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+
+	failures := s.AssertionFailures()
+	if len(failures) != 1 {
+		t.Fatalf("want 1 assertion failure; got: %v", failures)
+	}
+	if !strings.Contains(failures[0], "/path") || !strings.Contains(failures[0], "X-Api-Key") {
+		t.Fatalf("unexpected failure message: %s", failures[0])
+	}
+	if !testT.Failed() {
+		t.Fatalf("expected assertion failure to be reported to testing.T")
+	}
+}
+
+func TestErr(t *testing.T) {
+	var (
+		errA = errors.New("bad body a")
+		errB = errors.New("bad body b")
+	)
+	failWith := func(err error) httpfixture.FixtureOpt {
+		return httpfixture.AssertBodyStreaming(func(r io.Reader) error { return err })
+	}
+	s := httpfixture.NewServer(
+		httpfixture.GetOK("/a", "", failWith(errA)),
+		httpfixture.GetOK("/b", "", failWith(errB)),
+	)
+	s.Start(&testing.T{})
+	defer s.Close()
+
+	if err := s.Err(); err != nil {
+		t.Fatalf("want nil error before any requests; got: %v", err)
+	}
+	for _, path := range []string{"/a", "/b"} {
+		if _, err := http.Get(s.URL() + path); err != nil {
+			t.Fatalf("error making request: %v", err)
+		}
+	}
+	err := s.Err()
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Fatalf("want error wrapping both failures; got: %v", err)
+	}
+}
+
+func TestMatchHostRoutePrecedence(t *testing.T) {
+	s := httpfixture.NewServer(
+		httpfixture.GetOK("/api", "tenant a", httpfixture.MatchHost("a.example.com")),
+		httpfixture.GetOK("/api/users", "users"),
+	)
+	s.Start(t)
+	defer s.Close()
+
+	tests := []struct {
+		path     string
+		wantBody string
+	}{
+		{path: "/api/users", wantBody: "users"},
+		{path: "/api/orders", wantBody: "tenant a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := must(http.NewRequest(http.MethodGet, s.URL()+tt.path, nil))
+			req.Host = "a.example.com"
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if body := string(must(io.ReadAll(resp.Body))); body != tt.wantBody {
+				t.Fatalf("want body: '%s'; got: '%s'", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestMatchHost(t *testing.T) {
+	s := httpfixture.NewServer(
+		httpfixture.GetOK("/tenant", "default"),
+		httpfixture.GetOK("/tenant", "tenant a", httpfixture.MatchHost("a.example.com")),
+		httpfixture.GetOK("/tenant", "tenant b on 8443", httpfixture.MatchHost("b.example.com:8443")),
+		httpfixture.GetOK("/tenant", "tenant b", httpfixture.MatchHost("B.example.com")),
+	)
+	s.Start(t)
+	defer s.Close()
+
+	tests := []struct {
+		host     string
+		wantBody string
+	}{
+		{host: "a.example.com", wantBody: "tenant a"},
+		{host: "b.example.com:8080", wantBody: "tenant b"},
+		{host: "b.example.com:8443", wantBody: "tenant b on 8443"},
+		{host: "c.example.com", wantBody: "default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			req := must(http.NewRequest(http.MethodGet, s.URL()+"/tenant", nil))
+			req.Host = tt.host
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if body := string(must(io.ReadAll(resp.Body))); body != tt.wantBody {
+				t.Fatalf("want body: '%s'; got: '%s'", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+const jsonPathBody = `{"user":{"name":"gopher","address":{"city":"Mountain View","zip":94043}},"items":[{"id":"a1"},{"id":"b2","tags":[true]}]}`
+
+func TestEventuallyFound(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.EventuallyFound("/jobs/1", http.MethodGet, 3, "done"))
+	s.Start(t)
+	defer s.Close()
+
+	var attempts int
+	for {
+		attempts++
+		resp, err := http.Get(s.URL() + "/jobs/1")
+		if err != nil {
+			t.Fatalf("error making request: %v", err)
+		}
+		if resp.StatusCode == http.StatusOK {
+			if body := string(must(io.ReadAll(resp.Body))); body != "done" {
+				t.Fatalf("want body: 'done'; got: '%s'", body)
+			}
+			break
+		}
+		if resp.StatusCode != http.StatusNotFound || attempts > 10 {
+			t.Fatalf("unexpected statusCode %d after %d attempts", resp.StatusCode, attempts)
+		}
+	}
+	if attempts != 4 {
+		t.Fatalf("want success on attempt 4; got: %d", attempts)
+	}
+}
+
+func TestEventuallyFoundNegativeCount(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.EventuallyFound("/jobs/1", http.MethodGet, -1, "done"))
+	s.Start(t)
+	defer s.Close()
+
+	resp, err := http.Get(s.URL() + "/jobs/1")
+	if err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want statusCode: %d; got: %d", http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestResponse(t *testing.T) {
+	recorded := &http.Response{
+		StatusCode: http.StatusAccepted,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"status":"queued"}`)),
+	}
+	s := httpfixture.NewServer(httpfixture.Response("/jobs", http.MethodPost, recorded))
+	s.Start(t)
+	defer s.Close()
+
+	for i := 0; i < 3; i++ {
+		resp, err := http.Post(s.URL()+"/jobs", "application/json", nil)
+		if err != nil {
+			t.Fatalf("error making request: %v", err)
+		}
+		if resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("want statusCode: %d; got: %d", http.StatusAccepted, resp.StatusCode)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+			t.Fatalf("want Content-Type: application/json; got: %s", ct)
+		}
+		if body := string(must(io.ReadAll(resp.Body))); body != `{"status":"queued"}` {
+			t.Fatalf("request %d: want body: '%s'; got: '%s'", i, `{"status":"queued"}`, body)
+		}
+	}
+	if body := string(must(io.ReadAll(recorded.Body))); body != `{"status":"queued"}` {
+		t.Fatalf("want original response body to remain readable; got: '%s'", body)
+	}
+}
+
+func TestStatusFunc(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.StatusFunc("/validate", http.MethodGet, func(req *http.Request) int {
+		if req.Header.Get("X-Valid") == "true" {
+			return http.StatusOK
+		}
+		return http.StatusBadRequest
+	}, "checked"))
+	s.Start(t)
+	defer s.Close()
+
+	tests := []struct {
+		name     string
+		valid    string
+		wantCode int
+	}{
+		{name: "valid", valid: "true", wantCode: http.StatusOK},
+		{name: "invalid", valid: "false", wantCode: http.StatusBadRequest},
+		{name: "missing", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := must(http.NewRequest(http.MethodGet, s.URL()+"/validate", nil))
+			if tt.valid != "" {
+				req.Header.Set("X-Valid", tt.valid)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("want statusCode: %d; got: %d", tt.wantCode, resp.StatusCode)
+			}
+			if body := string(must(io.ReadAll(resp.Body))); body != "checked" {
+				t.Fatalf("want body: 'checked'; got: '%s'", body)
+			}
+		})
+	}
+}
+
+func TestMultipartMixed(t *testing.T) {
+	parts := []httpfixture.MultipartPart{
+		{Header: http.Header{"Content-Type": {"application/json"}}, Body: []byte(`{"id":1}`)},
+		{Header: http.Header{"Content-Type": {"text/plain"}, "Content-Id": {"<2>"}}, Body: []byte("second")},
+	}
+	s := httpfixture.NewServer(httpfixture.MultipartMixed("/batch", parts))
+	s.Start(t)
+	defer s.Close()
+
+	resp, err := http.Post(s.URL()+"/batch", "multipart/mixed", nil)
+	if err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("want multipart/mixed Content-Type; got: %s", resp.Header.Get("Content-Type"))
+	}
+	r := multipart.NewReader(resp.Body, params["boundary"])
+	for i, want := range parts {
+		part, err := r.NextPart()
+		if err != nil {
+			t.Fatalf("error reading part %d: %v", i, err)
+		}
+		for key := range want.Header {
+			if got := part.Header.Get(key); got != want.Header.Get(key) {
+				t.Errorf("part %d: want %s header: %s; got: %s", i, key, want.Header.Get(key), got)
+			}
+		}
+		if body := must(io.ReadAll(part)); !bytes.Equal(body, want.Body) {
+			t.Errorf("part %d: want body: '%s'; got: '%s'", i, want.Body, body)
+		}
+	}
+	if _, err := r.NextPart(); !errors.Is(err, io.EOF) {
+		t.Fatalf("want exactly %d parts; got error: %v", len(parts), err)
+	}
+}
+
+func TestTraceEcho(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.TraceEcho("/echo"))
+	s.Start(t)
+	defer s.Close()
+
+	req, err := http.NewRequest(http.MethodTrace, s.URL()+"/echo/path?q=1", nil)
+	if err != nil {
+		t.Fatalf("error creating request: %v", err)
+	}
+	req.Header.Set("X-Trace", "traced")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want statusCode: %d; got: %d", http.StatusOK, resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "message/http" {
+		t.Fatalf("want Content-Type: message/http; got: %s", ct)
+	}
+	body := string(must(io.ReadAll(resp.Body)))
+	for _, want := range []string{"TRACE /echo/path?q=1 HTTP/1.1\r\n", "X-Trace: traced\r\n"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want echoed request to contain %q; got: %q", want, body)
+		}
+	}
+}
+
+func TestPathFunc(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.PathFunc("/len", http.MethodGet, func(path string) (int, []byte) {
+		if path == "/len/teapot" {
+			return http.StatusTeapot, nil
+		}
+		return http.StatusOK, []byte(strconv.Itoa(len(path)))
+	}))
+	s.Start(t)
+	defer s.Close()
+
+	tests := []struct {
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{path: "/len", wantCode: http.StatusOK, wantBody: "4"},
+		{path: "/len/abcdef", wantCode: http.StatusOK, wantBody: "11"},
+		{path: "/len/teapot", wantCode: http.StatusTeapot},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp, err := http.Get(s.URL() + tt.path)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("want statusCode: %d; got: %d", tt.wantCode, resp.StatusCode)
+			}
+			if body := string(must(io.ReadAll(resp.Body))); body != tt.wantBody {
+				t.Fatalf("want body: '%s'; got: '%s'", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestWithTrailer(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.GetOK("/rpc", "message",
+		httpfixture.WithTrailer("Grpc-Status", "0"),
+		httpfixture.WithTrailer("Grpc-Message", "ok")))
+	s.Start(t)
+	defer s.Close()
+
+	resp, err := http.Get(s.URL() + "/rpc")
+	if err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	if len(resp.TransferEncoding) != 1 || resp.TransferEncoding[0] != "chunked" {
+		t.Fatalf("want chunked transfer encoding; got: %v", resp.TransferEncoding)
+	}
+	if body := string(must(io.ReadAll(resp.Body))); body != "message" {
+		t.Fatalf("want body: 'message'; got: '%s'", body)
+	}
+	if got := resp.Trailer.Get("Grpc-Status"); got != "0" {
+		t.Fatalf("want Grpc-Status trailer: '0'; got: '%s'", got)
+	}
+	if got := resp.Trailer.Get("Grpc-Message"); got != "ok" {
+		t.Fatalf("want Grpc-Message trailer: 'ok'; got: '%s'", got)
+	}
+}
+
+func TestVersioned(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.Versioned("/doc", "v1 body"))
+	s.Start(t)
+	defer s.Close()
+
+	get := func(wantETag, wantBody string) {
+		t.Helper()
+		resp, err := http.Get(s.URL() + "/doc")
+		if err != nil {
+			t.Fatalf("error making request: %v", err)
+		}
+		if got := resp.Header.Get("ETag"); got != wantETag {
+			t.Fatalf("want ETag: %s; got: %s", wantETag, got)
+		}
+		if body := string(must(io.ReadAll(resp.Body))); body != wantBody {
+			t.Fatalf("want body: '%s'; got: '%s'", wantBody, body)
+		}
+	}
+	put := func(ifMatch, body string, wantCode int) {
+		t.Helper()
+		req := must(http.NewRequest(http.MethodPut, s.URL()+"/doc", strings.NewReader(body)))
+		req.Header.Set("If-Match", ifMatch)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error making request: %v", err)
+		}
+		if resp.StatusCode != wantCode {
+			t.Fatalf("want statusCode: %d; got: %d", wantCode, resp.StatusCode)
+		}
+	}
+
+	get(`"1"`, "v1 body")
+	put(`"1"`, "v2 body", http.StatusNoContent)
+	get(`"2"`, "v2 body")
+	put(`"1"`, "stale body", http.StatusPreconditionFailed)
+	get(`"2"`, "v2 body")
+	put(`"1", "2"`, "v3 body", http.StatusNoContent)
+	get(`"3"`, "v3 body")
+	put(`W/"3"`, "weak body", http.StatusPreconditionFailed)
+	put("*", "v4 body", http.StatusNoContent)
+	get(`"4"`, "v4 body")
+}
+
+func TestSetClientCAs(t *testing.T) {
+	trustedCA, trustedKey := newTestCA(t, "trusted")
+	untrustedCA, untrustedKey := newTestCA(t, "untrusted")
+	pool := x509.NewCertPool()
+	pool.AddCert(trustedCA)
+
+	s := httpfixture.NewServer(httpfixture.GetOK("/secure", "hello"))
+	s.SetClientCAs(pool)
+	s.StartTLS(t)
+	defer s.Close()
+
+	tests := []struct {
+		name    string
+		ca      *x509.Certificate
+		caKey   *ecdsa.PrivateKey
+		wantErr bool
+	}{
+		{name: "trusted client", ca: trustedCA, caKey: trustedKey},
+		{name: "untrusted client", ca: untrustedCA, caKey: untrustedKey, wantErr: true},
+		{name: "no client certificate", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := s.Server.Client().Transport.(*http.Transport).Clone()
+			if tt.ca != nil {
+				transport.TLSClientConfig.Certificates = []tls.Certificate{newTestClientCert(t, tt.ca, tt.caKey)}
+			}
+			client := &http.Client{Transport: transport}
+			resp, err := client.Get(s.URL() + "/secure")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("want error for client; got status: %d", resp.StatusCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if body := string(must(io.ReadAll(resp.Body))); body != "hello" {
+				t.Fatalf("want body: 'hello'; got: '%s'", body)
+			}
+		})
+	}
+}
+
+// newTestCA creates a self-signed certificate authority.
+func newTestCA(t *testing.T, name string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key := must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader))
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der := must(x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key))
+	return must(x509.ParseCertificate(der)), key
+}
+
+// newTestClientCert creates a client certificate signed by the provided certificate authority.
+func newTestClientCert(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey) tls.Certificate {
+	t.Helper()
+	key := must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader))
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der := must(x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey))
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestHijack(t *testing.T) {
+	s := httpfixture.NewServer(
+		httpfixture.Hijack("/truncated", http.MethodGet, func(conn net.Conn) {
+			_, _ = io.WriteString(conn, "HTTP/1.1 200 OK\r\nContent-Length: 100\r\n\r\nshort")
+		}),
+		httpfixture.ResetConnection("/reset", http.MethodGet),
+	)
+	s.Start(t)
+	defer s.Close()
+
+	t.Run("truncated", func(t *testing.T) {
+		resp, err := http.Get(s.URL() + "/truncated")
+		if err != nil {
+			t.Fatalf("error making request: %v", err)
+		}
+		if _, err := io.ReadAll(resp.Body); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("want unexpected EOF reading truncated body; got: %v", err)
+		}
+	})
+	t.Run("reset", func(t *testing.T) {
+		if _, err := http.Get(s.URL() + "/reset"); err == nil {
+			t.Fatalf("expected error from reset connection")
+		}
+	})
+}
+
+func TestBodyAssertionsWithoutBody(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  httpfixture.FixtureOpt
+	}{
+		{name: "AssertBodyContains", opt: httpfixture.AssertBodyContains("hello")},
+		{name: "AssertBodyContainsBytes", opt: httpfixture.AssertBodyContainsBytes([]byte{0x01})},
+		{name: "AssertJSONPath", opt: httpfixture.AssertJSONPath("user.name", "gopher")},
+		{name: "AssertXMLHasElement", opt: httpfixture.AssertXMLHasElement("Envelope")},
+		{name: "AssertBodyIsGzip", opt: httpfixture.AssertBodyIsGzip()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if err := recover(); err != nil {
+					t.Fatalf("caught panic in test: %v", err)
+				}
+			}()
+			for _, body := range []io.ReadCloser{nil, http.NoBody} {
+				req := must(http.NewRequest(http.MethodGet, "http://localhost:7070/path", nil))
+				req.Body = body
+				testT := &testing.T{}
+				_ = httpfixture.GetOK("/path", "", tt.opt).Run(testT, req)
+				if !testT.Failed() {
+					t.Fatalf("want failure for request with body %v", body)
+				}
+			}
+
+			s := httpfixture.NewServer(httpfixture.GetOK("/path", "", tt.opt))
+			s.Start(&testing.T{})
+			defer s.Close()
+			if _, err := http.Get(s.URL() + "/path"); err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			failures := s.AssertionFailures()
+			if len(failures) != 1 || !strings.Contains(failures[0], "request body was empty") {
+				t.Fatalf("want 1 failure reporting an empty body; got: %v", failures)
+			}
+		})
+	}
+}
+
+func TestFixtureAssertions(t *testing.T) {
+	tests := []struct {
+		name        string
+		req         *http.Request
+		fixture     httpfixture.F
+		wantFailure bool
+	}{
+		{
+			name: "AssertBodyContains",
+			req: must(http.NewRequest("GET", "http://localhost:8080/path",
+				bytes.NewBufferString("the quick brown fox jumped over the"))),
+			fixture: httpfixture.GetOK("/path", "",
+				httpfixture.AssertBodyContains("quick brown fox")),
+		},
+		{
+			name: "AssertBodyContains failure",
+			req: must(http.NewRequest("GET", "http://localhost:8080/path/another",
+				bytes.NewBufferString("something else even"))),
+			fixture: httpfixture.GetOK("/path/another", "response body",
+				httpfixture.AssertBodyContains("quick brown fox")),
+			wantFailure: true,
+		},
+		{
+			name: "AssertBodyContainsBytes",
+			req: must(http.NewRequest("GET", "http://localhost:8080/path",
+				bytes.NewBufferString("some text\n\n\rother text here"))),
+			fixture: httpfixture.GetOK("/path", "",
+				httpfixture.AssertBodyContainsBytes([]byte("\n\n\r"))),
+		},
+		{
+			name: "AssertBodyContainsBytes failure",
+			req: must(http.NewRequest("GET", "http://localhost:8080/path",
+				bytes.NewBufferString(""))),
+			fixture: httpfixture.GetOK("/path", "",
+				httpfixture.AssertBodyContainsBytes([]byte("o"))),
+			wantFailure: true,
+		},
+		{
+			name: "AssertFormValue",
+			req: withHeader(must(http.NewRequest("POST", "http://localhost:8080/path", bytes.NewBufferString("a=1&b=2"))),
+				"Content-Type", "application/x-www-form-urlencoded"),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertFormValue("a", "1"),
+				httpfixture.AssertFormValue("b", "2"),
+				httpfixture.AssertBodyContains("a=1&b=2")),
+		},
+		{
+			name: "AssertFormValue mismatch",
+			req: withHeader(must(http.NewRequest("POST", "http://localhost:8080/path", bytes.NewBufferString("a=1&b=2"))),
+				"Content-Type", "application/x-www-form-urlencoded"),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertFormValue("b", "1")),
+			wantFailure: true,
+		},
+		{
+			name: "AssertFormValue missing",
+			req: withHeader(must(http.NewRequest("POST", "http://localhost:8080/path", bytes.NewBufferString("a=1&b=2"))),
+				"Content-Type", "application/x-www-form-urlencoded"),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertFormValue("c", "3")),
+			wantFailure: true,
+		},
+		{
+			name: "AssertBodyIsFormEncoded",
+			req: withHeader(must(http.NewRequest("POST", "http://localhost:8080/path", bytes.NewBufferString("a=1&b=%20"))),
+				"Content-Type", "application/x-www-form-urlencoded; charset=utf-8"),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertBodyIsFormEncoded(),
+				httpfixture.AssertBodyContains("a=1")),
+		},
+		{
+			name: "AssertBodyIsFormEncoded wrong Content-Type",
+			req: withHeader(must(http.NewRequest("POST", "http://localhost:8080/path", bytes.NewBufferString(`{"a":1}`))),
+				"Content-Type", "application/json"),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertBodyIsFormEncoded()),
+			wantFailure: true,
+		},
+		{
+			name: "AssertBodyIsFormEncoded malformed body",
+			req: withHeader(must(http.NewRequest("POST", "http://localhost:8080/path", bytes.NewBufferString("a=%zz"))),
+				"Content-Type", "application/x-www-form-urlencoded"),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertBodyIsFormEncoded()),
+			wantFailure: true,
+		},
+		{
+			name: "AssertMultipartFieldCount",
+			req: multipartRequest(func(w *multipart.Writer) {
+				_ = w.WriteField("name", "gopher")
+				_ = w.WriteField("role", "mascot")
+			}),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertMultipartFieldCount(2),
+				httpfixture.AssertBodyContains("gopher")),
+		},
+		{
+			name: "AssertMultipartFieldCount failure",
+			req: multipartRequest(func(w *multipart.Writer) {
+				_ = w.WriteField("name", "gopher")
+			}),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertMultipartFieldCount(2)),
+			wantFailure: true,
+		},
+		{
+			name: "AssertMultipartFieldCount not multipart",
+			req: withHeader(must(http.NewRequest("POST", "http://localhost:8080/path", bytes.NewBufferString("a=1"))),
+				"Content-Type", "application/x-www-form-urlencoded"),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertMultipartFieldCount(1)),
+			wantFailure: true,
+		},
+		{
+			name: "AssertXMLHasElement",
+			req: must(http.NewRequest("POST", "http://localhost:8080/path", bytes.NewBufferString(
+				`<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope"><soap:Header/>`+
+					`<soap:Body><Request><Id>1</Id></Request></soap:Body></soap:Envelope>`))),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertXMLHasElement("Envelope.Body.Request")),
+		},
+		{
+			name: "AssertXMLHasElement missing",
+			req: must(http.NewRequest("POST", "http://localhost:8080/path", bytes.NewBufferString(
+				`<Envelope><Header><Request/></Header><Body/></Envelope>`))),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertXMLHasElement("Envelope.Body.Request")),
+			wantFailure: true,
+		},
+		{
+			name: "AssertXMLHasElement invalid xml",
+			req:  must(http.NewRequest("POST", "http://localhost:8080/path", bytes.NewBufferString(`<Envelope><Body>`))),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertXMLHasElement("Envelope.Body.Request")),
+			wantFailure: true,
+		},
+		{
+			name: "AssertJSONPath nested object",
+			req:  must(http.NewRequest("POST", "http://localhost:8080/path", bytes.NewBufferString(jsonPathBody))),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertJSONPath("user.address.city", "Mountain View"),
+				httpfixture.AssertJSONPath("user.address.zip", "94043")),
+		},
+		{
+			name: "AssertJSONPath array index",
+			req:  must(http.NewRequest("POST", "http://localhost:8080/path", bytes.NewBufferString(jsonPathBody))),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertJSONPath("items.1.id", "b2"),
+				httpfixture.AssertJSONPath("items.1.tags.0", "true")),
+		},
+		{
+			name: "AssertJSONPath mismatch",
+			req:  must(http.NewRequest("POST", "http://localhost:8080/path", bytes.NewBufferString(jsonPathBody))),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertJSONPath("user.name", "ferris")),
+			wantFailure: true,
+		},
+		{
+			name: "AssertJSONPath unresolved",
+			req:  must(http.NewRequest("POST", "http://localhost:8080/path", bytes.NewBufferString(jsonPathBody))),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertJSONPath("items.5.id", "a1")),
+			wantFailure: true,
+		},
+		{
+			name: "AssertBodyIsGzip",
+			req:  must(http.NewRequest("POST", "http://localhost:8080/path", bytes.NewReader(gzipBytes("compress me")))),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertBodyIsGzip()),
+		},
+		{
+			name: "AssertBodyIsGzip plain body",
+			req:  must(http.NewRequest("POST", "http://localhost:8080/path", bytes.NewBufferString("compress me"))),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertBodyIsGzip()),
+			wantFailure: true,
+		},
+		{
+			name: "AssertBodyIsGzip truncated",
+			req: must(http.NewRequest("POST", "http://localhost:8080/path",
+				bytes.NewReader(gzipBytes("compress me")[:15]))),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertBodyIsGzip()),
+			wantFailure: true,
+		},
+		{
+			name: "AssertBodyMatchesRegex",
+			req: must(http.NewRequest("POST", "http://localhost:8080/path",
+				bytes.NewBufferString(`{"id":"3f2b8c1e-9d4a-4e7b-a1c2-5d6e7f8a9b0c","created":"2023-05-01T12:00:00Z"}`))),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertBodyMatchesRegex(`"id":"[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}"`),
+				httpfixture.AssertBodyContains(`"created"`)),
+		},
+		{
+			name: "AssertBodyMatchesRegex failure",
+			req:  must(http.NewRequest("POST", "http://localhost:8080/path", bytes.NewBufferString(`{"id":"not-a-uuid"}`))),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertBodyMatchesRegex(`"id":"[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}"`)),
+			wantFailure: true,
+		},
+		{
+			name: "AssertMultipartField and AssertMultipartFileName",
+			req: multipartRequest(func(w *multipart.Writer) {
+				_ = w.WriteField("description", "holiday photos")
+				fw, _ := w.CreateFormFile("photos", "beach.jpg")
+				_, _ = fw.Write([]byte("jpeg bytes"))
+				fw, _ = w.CreateFormFile("photos", "sunset.jpg")
+				_, _ = fw.Write([]byte("more jpeg bytes"))
+			}),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertMultipartField("description", "holiday photos"),
+				httpfixture.AssertMultipartFileName("photos", "beach.jpg"),
+				httpfixture.AssertMultipartFileName("photos", "sunset.jpg"),
+				httpfixture.AssertBodyContains("more jpeg bytes")),
+		},
+		{
+			name: "AssertMultipartField mismatch",
+			req: multipartRequest(func(w *multipart.Writer) {
+				_ = w.WriteField("description", "work documents")
+			}),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertMultipartField("description", "holiday photos")),
+			wantFailure: true,
+		},
+		{
+			name: "AssertMultipartFileName mismatch",
+			req: multipartRequest(func(w *multipart.Writer) {
+				fw, _ := w.CreateFormFile("photos", "beach.jpg")
+				_, _ = fw.Write([]byte("jpeg bytes"))
+			}),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertMultipartFileName("photos", "mountain.jpg")),
+			wantFailure: true,
+		},
+		{
+			name: "AssertMultipartField missing boundary",
+			req: withHeader(must(http.NewRequest("POST", "http://localhost:8080/path", bytes.NewBufferString("a=1"))),
+				"Content-Type", "multipart/form-data"),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertMultipartField("a", "1")),
+			wantFailure: true,
+		},
+		{
+			name: "AssertHeaderMatches",
+			req: withHeader(must(http.NewRequest("GET", "http://localhost:7070/path", nil)),
+				"Content-Type", "application/json"),
+			fixture: httpfixture.GetOK("/path", "",
+				httpfixture.AssertHeaderMatches("Content-Type", "application/json")),
+		},
+		{
+			name: "AssertHeaderMatches failure",
+			req:  must(http.NewRequest("GET", "http://localhost:7070/path", nil)),
+			fixture: httpfixture.GetOK("/path", "",
+				httpfixture.AssertHeaderMatches("Content-Type", "application/json")),
+			wantFailure: true,
+		},
+		{
+			name: "AssertHeaderAbsent",
+			req: withHeader(must(http.NewRequest("GET", "http://localhost:7070/path", nil)),
+				"Content-Type", "application/json"),
+			fixture: httpfixture.GetOK("/path", "",
+				httpfixture.AssertHeaderAbsent("Authorization")),
+		},
+		{
+			name: "AssertHeaderAbsent failure",
+			req: withHeader(must(http.NewRequest("GET", "http://localhost:7070/path", nil)),
+				"Authorization", "Bearer token"),
+			fixture: httpfixture.GetOK("/path", "",
+				httpfixture.AssertHeaderAbsent("Authorization")),
+			wantFailure: true,
+		},
+		{
+			name: "AssertHeaderCount",
+			req: withHeader(withHeader(must(http.NewRequest("GET", "http://localhost:7070/path", nil)),
+				"X-Forwarded-For", "10.0.0.1"), "X-Forwarded-For", "10.0.0.2"),
+			fixture: httpfixture.GetOK("/path", "",
+				httpfixture.AssertHeaderCount("X-Forwarded-For", 2),
+				httpfixture.AssertHeaderCount("Authorization", 0)),
+		},
+		{
+			name: "AssertHeaderCount failure",
+			req: withHeader(withHeader(must(http.NewRequest("GET", "http://localhost:7070/path", nil)),
+				"X-Forwarded-For", "10.0.0.1"), "X-Forwarded-For", "10.0.0.1"),
+			fixture: httpfixture.GetOK("/path", "",
+				httpfixture.AssertHeaderCount("X-Forwarded-For", 1)),
+			wantFailure: true,
+		},
+		{
+			name: "AssertIdempotencyKeyIsUUID",
+			req: withHeader(must(http.NewRequest("POST", "http://localhost:7070/path", nil)),
+				"Idempotency-Key", "123e4567-E89B-12d3-a456-426614174000"),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertIdempotencyKeyIsUUID("Idempotency-Key")),
+		},
+		{
+			name: "AssertIdempotencyKeyIsUUID malformed",
+			req: withHeader(must(http.NewRequest("POST", "http://localhost:7070/path", nil)),
+				"Idempotency-Key", "123e4567-e89b-12d3-a456-42661417400"),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertIdempotencyKeyIsUUID("Idempotency-Key")),
+			wantFailure: true,
+		},
+		{
+			name:        "AssertIdempotencyKeyIsUUID missing",
+			req:         must(http.NewRequest("POST", "http://localhost:7070/path", nil)),
+			fixture:     httpfixture.OK("/path", "", httpfixture.AssertIdempotencyKeyIsUUID("Idempotency-Key")),
+			wantFailure: true,
+		},
+		{
+			name: "AssertExpect",
+			req: withHeader(must(http.NewRequest("PUT", "http://localhost:7070/path", strings.NewReader("body"))),
+				"Expect", "100-Continue"),
+			fixture: httpfixture.BytesOK("/path", "PUT", nil,
+				httpfixture.AssertExpect("100-continue")),
+		},
+		{
+			name:        "AssertExpect failure absent",
+			req:         must(http.NewRequest("PUT", "http://localhost:7070/path", strings.NewReader("body"))),
+			fixture:     httpfixture.BytesOK("/path", "PUT", nil, httpfixture.AssertExpect("100-continue")),
+			wantFailure: true,
+		},
+		{
+			name: "AssertHeaderMatchesRegex",
+			req: withHeader(must(http.NewRequest("GET", "http://localhost:7070/path", nil)),
+				"X-Request-Id", "req-12345"),
+			fixture: httpfixture.GetOK("/path", "",
+				httpfixture.AssertHeaderMatchesRegex("X-Request-Id", `^req-[0-9]+$`)),
+		},
+		{
+			name: "AssertHeaderMatchesRegex failure",
+			req: withHeader(must(http.NewRequest("GET", "http://localhost:7070/path", nil)),
+				"X-Request-Id", "abcde"),
+			fixture: httpfixture.GetOK("/path", "",
+				httpfixture.AssertHeaderMatchesRegex("X-Request-Id", `^req-[0-9]+$`)),
+			wantFailure: true,
+		},
+		{
+			name: "AssertDateHeaderWithin",
+			req: withHeader(must(http.NewRequest("GET", "http://localhost:7070/path", nil)),
+				"Date", time.Now().UTC().Format(http.TimeFormat)),
+			fixture: httpfixture.GetOK("/path", "",
+				httpfixture.AssertDateHeaderWithin(time.Minute)),
+		},
+		{
+			name: "AssertDateHeaderWithin stale",
+			req: withHeader(must(http.NewRequest("GET", "http://localhost:7070/path", nil)),
+				"Date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)),
+			fixture: httpfixture.GetOK("/path", "",
+				httpfixture.AssertDateHeaderWithin(time.Minute)),
+			wantFailure: true,
+		},
+		{
+			name: "AssertDateHeaderWithin missing",
+			req:  must(http.NewRequest("GET", "http://localhost:7070/path", nil)),
+			fixture: httpfixture.GetOK("/path", "",
+				httpfixture.AssertDateHeaderWithin(time.Minute)),
+			wantFailure: true,
+		},
+		{
+			name: "AssertCookie",
+			req: withHeader(must(http.NewRequest("GET", "http://localhost:7070/path", nil)),
+				"Cookie", "session=abc123; theme=dark"),
+			fixture: httpfixture.GetOK("/path", "",
+				httpfixture.AssertCookie("session", "abc123")),
+		},
+		{
+			name: "AssertCookie wrong value",
+			req: withHeader(must(http.NewRequest("GET", "http://localhost:7070/path", nil)),
+				"Cookie", "session=xyz789"),
+			fixture: httpfixture.GetOK("/path", "",
+				httpfixture.AssertCookie("session", "abc123")),
+			wantFailure: true,
+		},
+		{
+			name: "AssertCookie missing",
+			req: withHeader(must(http.NewRequest("GET", "http://localhost:7070/path", nil)),
+				"Cookie", "theme=dark"),
+			fixture: httpfixture.GetOK("/path", "",
+				httpfixture.AssertCookie("session", "abc123")),
+			wantFailure: true,
+		},
+		{
+			name: "AssertQueryParamPresent with value",
+			req:  must(http.NewRequest("GET", "http://localhost:7070/path?debug=true", nil)),
+			fixture: httpfixture.GetOK("/path", "",
+				httpfixture.AssertQueryParamPresent("debug")),
+		},
+		{
+			name: "AssertQueryParamPresent empty",
+			req:  must(http.NewRequest("GET", "http://localhost:7070/path?verbose&debug", nil)),
+			fixture: httpfixture.GetOK("/path", "",
+				httpfixture.AssertQueryParamPresent("debug")),
+		},
+		{
+			name: "AssertQueryParamPresent absent",
+			req:  must(http.NewRequest("GET", "http://localhost:7070/path?verbose", nil)),
+			fixture: httpfixture.GetOK("/path", "",
+				httpfixture.AssertQueryParamPresent("debug")),
+			wantFailure: true,
+		},
+		{
+			name: "AssertQueryOrder",
+			req:  must(http.NewRequest("GET", "http://localhost:7070/path?a=1&x=0&b=2&c=3", nil)),
+			fixture: httpfixture.GetOK("/path", "",
+				httpfixture.AssertQueryOrder("a", "b", "c")),
+		},
+		{
+			name: "AssertQueryOrder scrambled",
+			req:  must(http.NewRequest("GET", "http://localhost:7070/path?b=2&a=1&c=3", nil)),
+			fixture: httpfixture.GetOK("/path", "",
+				httpfixture.AssertQueryOrder("a", "b", "c")),
+			wantFailure: true,
+		},
+		{
+			name: "AssertMethod",
+			req:  must(http.NewRequest("PATCH", "http://localhost:7070/path", nil)),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertMethod(http.MethodPatch)),
+		},
+		{
+			name: "AssertMethod lowercase",
+			req:  must(http.NewRequest("PATCH", "http://localhost:7070/path", nil)),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertMethod("patch")),
+		},
+		{
+			name: "AssertMethod failure",
+			req:  must(http.NewRequest("POST", "http://localhost:7070/path", nil)),
+			fixture: httpfixture.OK("/path", "",
+				httpfixture.AssertMethod(http.MethodPatch)),
+			wantFailure: true,
+		},
+		{
+			name: "AssertURLContains",
+			req:  must(http.NewRequest("GET", "http://localhost:7070/tasks/1234/status", nil)),
+			fixture: httpfixture.GetOK("/path", "",
+				httpfixture.AssertURLContains("tasks/1234/")),
+		},
+		{
+			name: "AssertURLContains",
+			req:  must(http.NewRequest("GET", "http://localhost:7070/tasks/1234/status", nil)),
+			fixture: httpfixture.GetOK("/path", "",
+				httpfixture.AssertURLContains("tasks/4567/")),
+			wantFailure: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				err := recover()
+				if err != nil && !tt.wantFailure {
+					t.Fatalf("caught panic in test: %v", err)
+				}
+			}()
+			testT := &testing.T{}
+			_ = tt.fixture.Run(testT, tt.req)
+
+			if tt.wantFailure != testT.Failed() {
+				t.Fatalf("unexpected failure reported; want: %t; got: %t", tt.wantFailure, testT.Failed())
+			}
+		})
+	}
+}
+
+func BenchmarkServer(b *testing.B) {
+	s := httpfixture.NewServer(httpfixture.GetOK("/path", "hello world",
+		httpfixture.AssertHeaderMatches("Accept", "text/plain")))
+	s.Start(b)
+	defer s.Close()
+
+	req := must(http.NewRequest(http.MethodGet, s.URL()+"/path", nil))
+	req.Header.Set("Accept", "text/plain")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			b.Fatalf("error making request: %v", err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
+
+func BenchmarkBodyAssertions(b *testing.B) {
+	body := bytes.Repeat([]byte(`{"user":{"name":"gopher"},"padding":"0123456789abcdef"}`), 1024)
+	f := httpfixture.OK("/path", "",
+		httpfixture.AssertBodyContains("gopher"),
+		httpfixture.AssertBodyMatchesRegex(`"padding"`),
+		httpfixture.AssertBodyContainsBytes([]byte("abcdef")))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/path", bytes.NewReader(body))
+		if resp := f.Run(b, req); resp == nil {
+			b.Fatalf("want response")
+		}
+	}
+}
+
+// logRecorder is a testing.TB which records the messages logged through it, in addition to passing them on.
+type logRecorder struct {
+	testing.TB
+
+	mu   sync.Mutex
+	logs []string
+}
+
+func (r *logRecorder) Logf(format string, args ...any) {
+	r.TB.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logs = append(r.logs, fmt.Sprintf(format, args...))
+	r.TB.Logf(format, args...)
+}
+
+// String returns all recorded messages, one per line.
+func (r *logRecorder) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return strings.Join(r.logs, "\n")
+}
+
+func withHeader(req *http.Request, key, value string) *http.Request {
+	req.Header.Add(key, value)
+	return req
+}
+
+// multipartRequest creates a POST request with a multipart body written by the provided func.
+func multipartRequest(write func(w *multipart.Writer)) *http.Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	write(w)
+	if err := w.Close(); err != nil {
+		panic(err)
+	}
+	req := must(http.NewRequest("POST", "http://localhost:8080/path", &body))
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req
+}
+
+// gzipBytes returns the gzip-compressed form of the provided string.
+func gzipBytes(s string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write([]byte(s))
+	if err := zw.Close(); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
 }
 
 func must[T any](t T, err error) T {
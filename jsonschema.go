@@ -0,0 +1,56 @@
+package httpfixture
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/orkes-io/go-httpfixture/jsonschema"
+)
+
+// AssertJSONSchema asserts that all requests passed to this fixture have a JSON body which conforms to the JSON Schema
+// document in the file at schemaPath. Failures describe the location of the first violation in the body, such as
+// "$.items[0].id". The subset of JSON Schema draft 2020-12 supported is described in package jsonschema; references
+// must be local to the document. The schema is read and parsed by this func, which panics if it is invalid, or with an
+// error matching ErrFixtureFile if the file cannot be read.
+func AssertJSONSchema(schemaPath string) FixtureOpt {
+	b, err := os.ReadFile(schemaPath)
+	if err != nil {
+		panic(&fixtureFileError{path: schemaPath, err: err})
+	}
+	s, err := jsonschema.Parse(b)
+	if err != nil {
+		panic(fmt.Errorf("httpfixture: %s: %w", schemaPath, err))
+	}
+	return assertSchema(s)
+}
+
+// AssertJSONSchemaInline asserts that all requests passed to this fixture have a JSON body which conforms to the
+// provided JSON Schema, provided as a string literal. The subset of JSON Schema supported is described in package
+// jsonschema. The schema is parsed by this func, which panics if it is invalid.
+func AssertJSONSchemaInline(schema string) FixtureOpt {
+	s, err := jsonschema.Parse([]byte(schema))
+	if err != nil {
+		panic(fmt.Errorf("httpfixture: %w", err))
+	}
+	return assertSchema(s)
+}
+
+// assertSchema asserts that all requests passed to this fixture have a JSON body which conforms to the provided schema.
+func assertSchema(s *jsonschema.Schema) FixtureOpt {
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			body, err := readBody(req)
+			if err != nil {
+				return fmt.Errorf("error reading request body: %w", err)
+			}
+			if len(body) == 0 {
+				return fmt.Errorf("%w; expected a JSON body", errEmptyBody)
+			}
+			if err := s.ValidateJSON(body); err != nil {
+				return fmt.Errorf("body does not conform to schema: %w", err)
+			}
+			return nil
+		})
+	}
+}
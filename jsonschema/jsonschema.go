@@ -0,0 +1,395 @@
+// Package jsonschema validates decoded JSON values against JSON Schemas. It supports the subset of JSON Schema draft
+// 2020-12 which is also found in OpenAPI 3.0 Schema Objects, which covers the schemas used by most API specifications.
+//
+// The supported keywords are: type, nullable, enum, const, properties, required, additionalProperties, items,
+// minItems, maxItems, uniqueItems, minProperties, maxProperties, minLength, maxLength, pattern, minimum, maximum,
+// exclusiveMinimum, exclusiveMaximum, multipleOf, allOf, anyOf, oneOf, not, and $ref. Both the boolean form of
+// exclusiveMinimum and exclusiveMaximum used by OpenAPI 3.0 and the numeric form used by later drafts are supported.
+// Only local references, which are JSON pointers within the same document such as "#/components/schemas/Pet", can be
+// resolved. All other keywords, including format, are ignored.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a JSON Schema which values can be validated against.
+type Schema struct {
+	doc  interface{} // the document containing this schema, against which $refs are resolved.
+	node interface{}
+}
+
+// Parse parses the provided JSON Schema document.
+func Parse(b []byte) (*Schema, error) {
+	var doc interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing schema: %w", err)
+	}
+	return Compile(doc, "")
+}
+
+// Compile returns the schema found at the provided JSON pointer within doc, which is a JSON document decoded using
+// encoding/json. References within the schema are resolved against doc. An empty pointer refers to doc itself.
+func Compile(doc interface{}, pointer string) (*Schema, error) {
+	node, err := resolvePointer(doc, pointer)
+	if err != nil {
+		return nil, err
+	}
+	switch node.(type) {
+	case map[string]interface{}, bool:
+	default:
+		return nil, fmt.Errorf("schema at %q must be an object or boolean", pointer)
+	}
+	return &Schema{doc: doc, node: node}, nil
+}
+
+// ValidateJSON parses the provided JSON document and validates it against this schema.
+func (s *Schema) ValidateJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return fmt.Errorf("error parsing JSON: %w", err)
+	}
+	return s.Validate(v)
+}
+
+// Validate validates the provided value, which must be decoded using encoding/json into an interface{}, against this
+// schema. The returned error describes the first violation found, and the location in the value where it was found.
+func (s *Schema) Validate(v interface{}) error {
+	return s.validate(s.node, v, "$", 0)
+}
+
+// maxRefDepth limits how many $refs are followed while validating a single value, so that cyclic references which
+// never consume any of the value are reported as errors, instead of recursing forever.
+const maxRefDepth = 64
+
+func (s *Schema) validate(node, v interface{}, at string, refs int) error {
+	switch n := node.(type) {
+	case bool:
+		if !n {
+			return fmt.Errorf("%s: no value is allowed", at)
+		}
+		return nil
+	case map[string]interface{}:
+		return s.validateObject(n, v, at, refs)
+	}
+	return fmt.Errorf("%s: schema must be an object or boolean", at)
+}
+
+func (s *Schema) validateObject(n map[string]interface{}, v interface{}, at string, refs int) error {
+	if ref, ok := n["$ref"].(string); ok {
+		if refs >= maxRefDepth {
+			return fmt.Errorf("%s: too many nested references resolving %s", at, ref)
+		}
+		if !strings.HasPrefix(ref, "#") {
+			return fmt.Errorf("%s: unsupported non-local reference %s", at, ref)
+		}
+		target, err := resolvePointer(s.doc, strings.TrimPrefix(ref, "#"))
+		if err != nil {
+			return fmt.Errorf("%s: %w", at, err)
+		}
+		if err := s.validate(target, v, at, refs+1); err != nil {
+			return err
+		}
+	}
+	if v == nil {
+		if nullable, _ := n["nullable"].(bool); nullable {
+			return nil
+		}
+	}
+	if t, ok := n["type"]; ok {
+		if err := checkType(t, v, at); err != nil {
+			return err
+		}
+	}
+	if enum, ok := n["enum"].([]interface{}); ok {
+		var found bool
+		for _, e := range enum {
+			if equal(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value %s is not one of %s", at, describe(v), describe(enum))
+		}
+	}
+	if c, ok := n["const"]; ok && !equal(c, v) {
+		return fmt.Errorf("%s: value %s is not %s", at, describe(v), describe(c))
+	}
+	var err error
+	switch val := v.(type) {
+	case map[string]interface{}:
+		err = s.validateProperties(n, val, at, refs)
+	case []interface{}:
+		err = s.validateItems(n, val, at, refs)
+	case string:
+		err = validateString(n, val, at)
+	case float64:
+		err = validateNumber(n, val, at)
+	}
+	if err != nil {
+		return err
+	}
+	return s.validateCombinators(n, v, at, refs)
+}
+
+func (s *Schema) validateProperties(n map[string]interface{}, obj map[string]interface{}, at string, refs int) error {
+	if required, ok := n["required"].([]interface{}); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", at, name)
+			}
+		}
+	}
+	if min, ok := n["minProperties"].(float64); ok && float64(len(obj)) < min {
+		return fmt.Errorf("%s: object has %d properties; want at least %v", at, len(obj), min)
+	}
+	if max, ok := n["maxProperties"].(float64); ok && float64(len(obj)) > max {
+		return fmt.Errorf("%s: object has %d properties; want at most %v", at, len(obj), max)
+	}
+	props, _ := n["properties"].(map[string]interface{})
+	additional, hasAdditional := n["additionalProperties"]
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names) // report violations deterministically.
+	for _, name := range names {
+		if prop, ok := props[name]; ok {
+			if err := s.validate(prop, obj[name], at+"."+name, refs); err != nil {
+				return err
+			}
+			continue
+		}
+		if !hasAdditional {
+			continue
+		}
+		if err := s.validate(additional, obj[name], at+"."+name, refs); err != nil {
+			if allowed, ok := additional.(bool); ok && !allowed {
+				return fmt.Errorf("%s: unexpected property %q", at, name)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateItems(n map[string]interface{}, arr []interface{}, at string, refs int) error {
+	if min, ok := n["minItems"].(float64); ok && float64(len(arr)) < min {
+		return fmt.Errorf("%s: array has %d items; want at least %v", at, len(arr), min)
+	}
+	if max, ok := n["maxItems"].(float64); ok && float64(len(arr)) > max {
+		return fmt.Errorf("%s: array has %d items; want at most %v", at, len(arr), max)
+	}
+	if unique, _ := n["uniqueItems"].(bool); unique {
+		for i := range arr {
+			for j := i + 1; j < len(arr); j++ {
+				if equal(arr[i], arr[j]) {
+					return fmt.Errorf("%s: items %d and %d are equal", at, i, j)
+				}
+			}
+		}
+	}
+	items, ok := n["items"]
+	if !ok {
+		return nil
+	}
+	for i, item := range arr {
+		if err := s.validate(items, item, at+"["+strconv.Itoa(i)+"]", refs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateString(n map[string]interface{}, str, at string) error {
+	length := float64(utf8.RuneCountInString(str))
+	if min, ok := n["minLength"].(float64); ok && length < min {
+		return fmt.Errorf("%s: string has length %v; want at least %v", at, length, min)
+	}
+	if max, ok := n["maxLength"].(float64); ok && length > max {
+		return fmt.Errorf("%s: string has length %v; want at most %v", at, length, max)
+	}
+	if pattern, ok := n["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("%s: invalid pattern %q: %w", at, pattern, err)
+		}
+		if !re.MatchString(str) {
+			return fmt.Errorf("%s: string %q does not match pattern %q", at, str, pattern)
+		}
+	}
+	return nil
+}
+
+func validateNumber(n map[string]interface{}, num float64, at string) error {
+	if min, ok := n["minimum"].(float64); ok {
+		if exclusive, _ := n["exclusiveMinimum"].(bool); exclusive && num <= min {
+			return fmt.Errorf("%s: %v must be greater than %v", at, num, min)
+		}
+		if num < min {
+			return fmt.Errorf("%s: %v must be at least %v", at, num, min)
+		}
+	}
+	if max, ok := n["maximum"].(float64); ok {
+		if exclusive, _ := n["exclusiveMaximum"].(bool); exclusive && num >= max {
+			return fmt.Errorf("%s: %v must be less than %v", at, num, max)
+		}
+		if num > max {
+			return fmt.Errorf("%s: %v must be at most %v", at, num, max)
+		}
+	}
+	if min, ok := n["exclusiveMinimum"].(float64); ok && num <= min {
+		return fmt.Errorf("%s: %v must be greater than %v", at, num, min)
+	}
+	if max, ok := n["exclusiveMaximum"].(float64); ok && num >= max {
+		return fmt.Errorf("%s: %v must be less than %v", at, num, max)
+	}
+	if mult, ok := n["multipleOf"].(float64); ok && mult > 0 {
+		if q := num / mult; q != math.Trunc(q) {
+			return fmt.Errorf("%s: %v is not a multiple of %v", at, num, mult)
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateCombinators(n map[string]interface{}, v interface{}, at string, refs int) error {
+	if all, ok := n["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			if err := s.validate(sub, v, at, refs); err != nil {
+				return err
+			}
+		}
+	}
+	if anyOf, ok := n["anyOf"].([]interface{}); ok {
+		var matched bool
+		for _, sub := range anyOf {
+			if s.validate(sub, v, at, refs) == nil {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: value does not match any schema in anyOf", at)
+		}
+	}
+	if oneOf, ok := n["oneOf"].([]interface{}); ok {
+		var matched int
+		for _, sub := range oneOf {
+			if s.validate(sub, v, at, refs) == nil {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return fmt.Errorf("%s: value matches %d schemas in oneOf; want exactly 1", at, matched)
+		}
+	}
+	if not, ok := n["not"]; ok && s.validate(not, v, at, refs) == nil {
+		return fmt.Errorf("%s: value must not match schema in not", at)
+	}
+	return nil
+}
+
+// checkType returns an error if v does not have the provided type, which is either a type name or a list of them.
+func checkType(t, v interface{}, at string) error {
+	var types []string
+	switch tt := t.(type) {
+	case string:
+		types = []string{tt}
+	case []interface{}:
+		for _, name := range tt {
+			if s, ok := name.(string); ok {
+				types = append(types, s)
+			}
+		}
+	}
+	for _, name := range types {
+		if hasType(name, v) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: value %s is not of type %s", at, describe(v), strings.Join(types, " or "))
+}
+
+func hasType(name string, v interface{}) bool {
+	switch name {
+	case "null":
+		return v == nil
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	}
+	return false
+}
+
+// equal reports whether two decoded JSON values are equal.
+func equal(a, b interface{}) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+// describe returns a short JSON representation of v for use in error messages.
+func describe(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	if len(b) > 64 {
+		return string(b[:61]) + "..."
+	}
+	return string(b)
+}
+
+// resolvePointer returns the value at the provided JSON pointer, as defined by RFC 6901, within doc.
+func resolvePointer(doc interface{}, pointer string) (interface{}, error) {
+	if pointer == "" {
+		return doc, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+	curr := doc
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch c := curr.(type) {
+		case map[string]interface{}:
+			next, ok := c[token]
+			if !ok {
+				return nil, fmt.Errorf("could not resolve JSON pointer %q: no member %q", pointer, token)
+			}
+			curr = next
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil, fmt.Errorf("could not resolve JSON pointer %q: invalid index %q", pointer, token)
+			}
+			curr = c[i]
+		default:
+			return nil, fmt.Errorf("could not resolve JSON pointer %q", pointer)
+		}
+	}
+	return curr, nil
+}
@@ -0,0 +1,95 @@
+package jsonschema_test
+
+import (
+	"github.com/orkes-io/go-httpfixture/jsonschema"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		value   string
+		wantErr string
+	}{
+		{name: "type", schema: `{"type":"string"}`, value: `"a"`},
+		{name: "type mismatch", schema: `{"type":"string"}`, value: `1`, wantErr: "not of type string"},
+		{name: "type list", schema: `{"type":["string","null"]}`, value: `null`},
+		{name: "integer", schema: `{"type":"integer"}`, value: `1.5`, wantErr: "not of type integer"},
+		{name: "nullable", schema: `{"type":"string","nullable":true}`, value: `null`},
+		{name: "enum", schema: `{"enum":["a","b"]}`, value: `"c"`, wantErr: "not one of"},
+		{name: "const", schema: `{"const":{"a":1}}`, value: `{"a":1}`},
+		{
+			name:    "required",
+			schema:  `{"type":"object","required":["id"]}`,
+			value:   `{"name":"x"}`,
+			wantErr: `$: missing required property "id"`,
+		},
+		{
+			name:    "nested property",
+			schema:  `{"properties":{"user":{"properties":{"age":{"type":"integer","minimum":0}}}}}`,
+			value:   `{"user":{"age":-1}}`,
+			wantErr: "$.user.age: -1 must be at least 0",
+		},
+		{
+			name:    "additionalProperties false",
+			schema:  `{"properties":{"a":{}},"additionalProperties":false}`,
+			value:   `{"a":1,"b":2}`,
+			wantErr: `unexpected property "b"`,
+		},
+		{
+			name:    "additionalProperties schema",
+			schema:  `{"additionalProperties":{"type":"number"}}`,
+			value:   `{"a":1,"b":"2"}`,
+			wantErr: "$.b: value \"2\" is not of type number",
+		},
+		{name: "items", schema: `{"items":{"type":"string"}}`, value: `["a",1]`, wantErr: "$[1]"},
+		{name: "minItems", schema: `{"minItems":2}`, value: `[1]`, wantErr: "want at least 2"},
+		{name: "uniqueItems", schema: `{"uniqueItems":true}`, value: `[1,2,1]`, wantErr: "items 0 and 2 are equal"},
+		{name: "maxLength", schema: `{"maxLength":3}`, value: `"héllo"`, wantErr: "want at most 3"},
+		{name: "pattern", schema: `{"pattern":"^[a-z]+$"}`, value: `"abc"`},
+		{name: "pattern mismatch", schema: `{"pattern":"^[a-z]+$"}`, value: `"ABC"`, wantErr: "does not match"},
+		{name: "exclusiveMinimum bool", schema: `{"minimum":0,"exclusiveMinimum":true}`, value: `0`, wantErr: "greater"},
+		{name: "exclusiveMaximum number", schema: `{"exclusiveMaximum":10}`, value: `10`, wantErr: "less than 10"},
+		{name: "multipleOf", schema: `{"multipleOf":5}`, value: `12`, wantErr: "not a multiple"},
+		{name: "anyOf", schema: `{"anyOf":[{"type":"string"},{"type":"integer"}]}`, value: `3`},
+		{name: "oneOf", schema: `{"oneOf":[{"type":"number"},{"type":"integer"}]}`, value: `3`, wantErr: "matches 2"},
+		{name: "not", schema: `{"not":{"type":"null"}}`, value: `null`, wantErr: "must not match"},
+		{name: "false schema", schema: `false`, value: `1`, wantErr: "no value is allowed"},
+		{
+			name:   "ref",
+			schema: `{"$defs":{"id":{"type":"integer"}},"properties":{"id":{"$ref":"#/$defs/id"}}}`,
+			value:  `{"id":1}`,
+		},
+		{
+			name:    "ref mismatch",
+			schema:  `{"$defs":{"id":{"type":"integer"}},"properties":{"id":{"$ref":"#/$defs/id"}}}`,
+			value:   `{"id":"1"}`,
+			wantErr: "$.id",
+		},
+		{
+			name:    "recursive ref",
+			schema:  `{"properties":{"next":{"$ref":"#"}},"required":["v"]}`,
+			value:   `{"v":1,"next":{"v":2,"next":{}}}`,
+			wantErr: `$.next.next: missing required property "v"`,
+		},
+		{name: "cyclic ref", schema: `{"$ref":"#"}`, value: `1`, wantErr: "too many nested references"},
+		{name: "remote ref", schema: `{"$ref":"other.json"}`, value: `1`, wantErr: "unsupported non-local reference"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := jsonschema.Parse([]byte(tt.schema))
+			if err != nil {
+				t.Fatalf("error parsing schema: %v", err)
+			}
+			err = s.ValidateJSON([]byte(tt.value))
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("want error containing %q; got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package httpfixture_test
+
+import (
+	"errors"
+	"github.com/orkes-io/go-httpfixture"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const userSchema = `{
+	"type": "object",
+	"required": ["name", "age"],
+	"properties": {
+		"name": {"type": "string", "minLength": 1},
+		"age": {"type": "integer", "minimum": 0}
+	}
+}`
+
+func TestAssertJSONSchemaInline(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantFailure string
+	}{
+		{name: "conforming", body: `{"name":"gopher","age":13}`},
+		{name: "wrong type", body: `{"name":"gopher","age":"old"}`, wantFailure: "$.age"},
+		{name: "missing required property", body: `{"age":13}`, wantFailure: `"name"`},
+		{name: "not JSON", body: `name=gopher`, wantFailure: "error parsing JSON"},
+		{name: "empty", wantFailure: "request body was empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockT := &testing.T{}
+			s := httpfixture.NewServer(httpfixture.OK("/users", "", httpfixture.AssertJSONSchemaInline(userSchema)))
+			s.Start(mockT)
+			defer s.Close()
+
+			resp, err := http.Post(s.URL()+"/users", "application/json", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			resp.Body.Close()
+			if mockT.Failed() != (tt.wantFailure != "") {
+				t.Fatalf("want failure: %t; got: %t", tt.wantFailure != "", mockT.Failed())
+			}
+			failures := s.AssertionFailures()
+			if tt.wantFailure != "" && (len(failures) != 1 || !strings.Contains(failures[0], tt.wantFailure)) {
+				t.Fatalf("want 1 failure containing %q; got: %v", tt.wantFailure, failures)
+			}
+		})
+	}
+}
+
+func TestAssertJSONSchemaInlineInvalid(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatalf("want panic for invalid schema")
+		}
+	}()
+	httpfixture.AssertJSONSchemaInline(`{"type":`)
+}
+
+func TestAssertJSONSchema(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantFailure string
+	}{
+		{name: "conforming", body: `{"id":"o-1","items":[{"sku":"abc","quantity":2}]}`},
+		{name: "invalid item", body: `{"id":"o-1","items":[{"sku":"abc","quantity":0}]}`, wantFailure: "$.items[0].quantity"},
+		{name: "no items", body: `{"id":"o-1","items":[]}`, wantFailure: "$.items"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockT := &testing.T{}
+			s := httpfixture.NewServer(httpfixture.OK("/orders", "",
+				httpfixture.AssertJSONSchema("testdata/order.schema.json")))
+			s.Start(mockT)
+			defer s.Close()
+
+			resp, err := http.Post(s.URL()+"/orders", "application/json", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			resp.Body.Close()
+			if mockT.Failed() != (tt.wantFailure != "") {
+				t.Fatalf("want failure: %t; got: %t", tt.wantFailure != "", mockT.Failed())
+			}
+			failures := s.AssertionFailures()
+			if tt.wantFailure != "" && (len(failures) != 1 || !strings.Contains(failures[0], tt.wantFailure)) {
+				t.Fatalf("want 1 failure containing %q; got: %v", tt.wantFailure, failures)
+			}
+		})
+	}
+}
+
+func TestAssertJSONSchemaMissingFile(t *testing.T) {
+	defer func() {
+		err, ok := recover().(error)
+		if !ok || !errors.Is(err, httpfixture.ErrFixtureFile) {
+			t.Fatalf("want panic matching ErrFixtureFile; got: %v", err)
+		}
+	}()
+	httpfixture.AssertJSONSchema("testdata/missing.schema.json")
+}
@@ -0,0 +1,62 @@
+package httpfixture
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Distribution is a distribution of durations, used to simulate variable processing time.
+type Distribution interface {
+	// Sample returns a duration drawn from this distribution.
+	Sample() time.Duration
+}
+
+// WithLatencyDistribution delays responses from a fixture by a duration sampled from the provided distribution for each
+// request, in addition to any delay set by WithDelay. If the request is canceled by the client before the delay
+// elapses, no response is written.
+func WithLatencyDistribution(d Distribution) FixtureOpt {
+	return func(f *baseFixture) {
+		f.latency = d
+	}
+}
+
+// NormalDistribution returns a Distribution of durations which are normally distributed with the provided mean and
+// standard deviation, using a random source seeded with the provided seed. Negative samples are reported as zero. The
+// returned Distribution is safe for concurrent use.
+func NormalDistribution(mean, stddev time.Duration, seed int64) Distribution {
+	return &randDistribution{
+		rng: rand.New(rand.NewSource(seed)),
+		sample: func(rng *rand.Rand) float64 {
+			return rng.NormFloat64()*float64(stddev) + float64(mean)
+		},
+	}
+}
+
+// ExponentialDistribution returns a Distribution of durations which are exponentially distributed with the provided
+// mean, using a random source seeded with the provided seed. The returned Distribution is safe for concurrent use.
+func ExponentialDistribution(mean time.Duration, seed int64) Distribution {
+	return &randDistribution{
+		rng: rand.New(rand.NewSource(seed)),
+		sample: func(rng *rand.Rand) float64 {
+			return rng.ExpFloat64() * float64(mean)
+		},
+	}
+}
+
+// randDistribution samples durations using a random source.
+type randDistribution struct {
+	mu     sync.Mutex
+	rng    *rand.Rand
+	sample func(rng *rand.Rand) float64
+}
+
+func (rd *randDistribution) Sample() time.Duration {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	d := time.Duration(rd.sample(rd.rng))
+	if d < 0 {
+		return 0
+	}
+	return d
+}
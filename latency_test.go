@@ -0,0 +1,83 @@
+package httpfixture_test
+
+import (
+	"github.com/orkes-io/go-httpfixture"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDistributions(t *testing.T) {
+	tests := []struct {
+		name       string
+		dist       httpfixture.Distribution
+		wantMean   time.Duration
+		wantStddev time.Duration
+	}{
+		{
+			name:       "normal",
+			dist:       httpfixture.NormalDistribution(100*time.Millisecond, 10*time.Millisecond, 42),
+			wantMean:   100 * time.Millisecond,
+			wantStddev: 10 * time.Millisecond,
+		},
+		{
+			name:       "exponential",
+			dist:       httpfixture.ExponentialDistribution(50*time.Millisecond, 42),
+			wantMean:   50 * time.Millisecond,
+			wantStddev: 50 * time.Millisecond,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const n = 10000
+			samples := make([]float64, n)
+			var sum float64
+			for i := range samples {
+				d := tt.dist.Sample()
+				if d < 0 {
+					t.Fatalf("sampled negative duration: %s", d)
+				}
+				samples[i] = float64(d)
+				sum += samples[i]
+			}
+			mean := sum / n
+			var variance float64
+			for _, s := range samples {
+				variance += (s - mean) * (s - mean)
+			}
+			stddev := math.Sqrt(variance / n)
+			if math.Abs(mean-float64(tt.wantMean)) > 0.05*float64(tt.wantMean) {
+				t.Fatalf("want mean near %s; got: %s", tt.wantMean, time.Duration(mean))
+			}
+			if math.Abs(stddev-float64(tt.wantStddev)) > 0.1*float64(tt.wantStddev) {
+				t.Fatalf("want stddev near %s; got: %s", tt.wantStddev, time.Duration(stddev))
+			}
+		})
+	}
+}
+
+func TestDistributionSeeded(t *testing.T) {
+	a := httpfixture.NormalDistribution(time.Second, time.Millisecond, 7)
+	b := httpfixture.NormalDistribution(time.Second, time.Millisecond, 7)
+	for i := 0; i < 10; i++ {
+		if sa, sb := a.Sample(), b.Sample(); sa != sb {
+			t.Fatalf("sample %d: distributions with the same seed differed: %s != %s", i, sa, sb)
+		}
+	}
+}
+
+func TestWithLatencyDistribution(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.GetOK("/slow", "done",
+		httpfixture.WithLatencyDistribution(httpfixture.NormalDistribution(100*time.Millisecond, 0, 1))))
+	s.Start(t)
+	defer s.Close()
+
+	start := time.Now()
+	if _, err := http.Get(s.URL() + "/slow"); err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("want response delayed by at least 100ms; took: %s", elapsed)
+	}
+}
@@ -0,0 +1,68 @@
+package httpfixture
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/orkes-io/go-httpfixture/openapi"
+)
+
+// OpenAPIResponse returns a fixture for the operation with the provided operationId in the OpenAPI specification at
+// specPath, which must be in JSON format. The fixture matches the method and path of the operation, and responds with
+// the lowest 2xx status code declared by the operation and the provided JSON body. Since routes match any path beneath
+// them, a templated path is hosted at the part before its first template; e.g. "/pets/{petId}" is hosted at "/pets".
+//
+// The body is validated against the response schema declared by the operation, as described in package openapi. This
+// func panics if the specification cannot be read, the operation is not found, or the body does not conform.
+func OpenAPIResponse(specPath, operationID string, body string, opts ...FixtureOpt) F {
+	op := loadOperation(specPath, operationID)
+	code := op.SuccessStatus()
+	if err := op.ValidateResponse(code, "application/json", []byte(body)); err != nil {
+		panic(fmt.Errorf("httpfixture: body does not conform to response of operation %s: %w", operationID, err))
+	}
+	opts = append([]FixtureOpt{withHeader(http.Header{"Content-Type": {"application/json"}})}, opts...)
+	return Bytes(templateRoute(op.Path), op.Method, code, []byte(body), opts...)
+}
+
+// AssertOpenAPIRequest asserts that all requests passed to this fixture conform to the operation with the provided
+// operationId in the OpenAPI specification at specPath, which must be in JSON format. The method, path, path and query
+// parameters, headers, and JSON body of each request are validated, as described in package openapi. This func panics
+// if the specification cannot be read or the operation is not found.
+func AssertOpenAPIRequest(specPath, operationID string) FixtureOpt {
+	op := loadOperation(specPath, operationID)
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, func(req *http.Request) error {
+			body, err := readBody(req)
+			if err != nil {
+				return fmt.Errorf("error reading request body: %w", err)
+			}
+			if err := op.ValidateRequest(req, body); err != nil {
+				return fmt.Errorf("request does not conform to operation %s: %w", operationID, err)
+			}
+			return nil
+		})
+	}
+}
+
+// loadOperation loads the operation with the provided operationId from the OpenAPI specification at specPath,
+// panicking if it cannot be found.
+func loadOperation(specPath, operationID string) *openapi.Operation {
+	spec, err := openapi.Load(specPath)
+	if err != nil {
+		panic(fmt.Errorf("httpfixture: %w", err))
+	}
+	op, err := spec.Operation(operationID)
+	if err != nil {
+		panic(fmt.Errorf("httpfixture: %w", err))
+	}
+	return op
+}
+
+// templateRoute returns the route used to host a templated OpenAPI path, which is the path up to its first template.
+func templateRoute(path string) string {
+	if i := strings.IndexByte(path, '{'); i >= 0 {
+		path = path[:i]
+	}
+	return path
+}
@@ -0,0 +1,401 @@
+// Package openapi looks up operations in OpenAPI 3 specifications, and validates HTTP messages against the schemas
+// they declare. Only specifications in JSON format are supported. Schemas are validated using package jsonschema, and
+// support the subset of the Schema Object documented there.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/orkes-io/go-httpfixture/jsonschema"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// methods are the keys of a Path Item Object which contain operations.
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Spec is a parsed OpenAPI 3 specification.
+type Spec struct {
+	doc map[string]interface{}
+}
+
+// Load reads and parses the OpenAPI specification at the provided path.
+func Load(path string) (*Spec, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading OpenAPI spec: %w", err)
+	}
+	return Parse(b)
+}
+
+// Parse parses the provided OpenAPI specification.
+func Parse(b []byte) (*Spec, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing OpenAPI spec: %w", err)
+	}
+	if _, ok := doc["openapi"].(string); !ok {
+		return nil, fmt.Errorf("error parsing OpenAPI spec: missing openapi version field")
+	}
+	return &Spec{doc: doc}, nil
+}
+
+// Operation is a single operation declared by a Spec.
+type Operation struct {
+	// ID is the operationId of this operation.
+	ID string
+	// Method is the HTTP method of this operation, in upper case.
+	Method string
+	// Path is the templated path of this operation, like "/pets/{petId}".
+	Path string
+
+	spec     *Spec
+	pointer  string // the JSON pointer to this operation within the spec.
+	node     map[string]interface{}
+	pathItem map[string]interface{}
+}
+
+// Operation returns the operation with the provided operationId.
+func (s *Spec) Operation(id string) (*Operation, error) {
+	paths, _ := s.doc["paths"].(map[string]interface{})
+	for path, item := range paths {
+		pathItem, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, m := range methods {
+			op, ok := pathItem[m].(map[string]interface{})
+			if !ok || op["operationId"] != id {
+				continue
+			}
+			return &Operation{
+				ID:       id,
+				Method:   strings.ToUpper(m),
+				Path:     path,
+				spec:     s,
+				pointer:  "/paths/" + escapePointer(path) + "/" + m,
+				node:     op,
+				pathItem: pathItem,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("no operation with operationId %q", id)
+}
+
+// SuccessStatus returns the lowest 2xx status code declared in the responses of this operation, or 200 if none are.
+func (o *Operation) SuccessStatus() int {
+	responses, _ := o.node["responses"].(map[string]interface{})
+	var codes []int
+	for key := range responses {
+		if code, err := strconv.Atoi(key); err == nil && code >= 200 && code < 300 {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		return 200
+	}
+	sort.Ints(codes)
+	return codes[0]
+}
+
+// ResponseSchema returns the schema of responses with the provided status code and media type. Responses are looked
+// up by exact status code, then by range, like "2XX", then the default response. Media types are looked up exactly,
+// then by wildcard, like "application/*". A nil schema is returned if the response has no content of any media type.
+// It is an error for the operation to declare no response matching the status code, or no content matching the media
+// type.
+func (o *Operation) ResponseSchema(status int, mediaType string) (*jsonschema.Schema, error) {
+	responses, _ := o.node["responses"].(map[string]interface{})
+	code := strconv.Itoa(status)
+	for _, key := range []string{code, code[:1] + "XX", "default"} {
+		resp, ok := responses[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		return o.contentSchema(resp, o.pointer+"/responses/"+key, mediaType)
+	}
+	return nil, fmt.Errorf("operation %s declares no response with status %d", o.ID, status)
+}
+
+// ValidateResponse validates a response with the provided status code, Content-Type, and body against the schema
+// declared by this operation. Only JSON bodies can be validated: bodies of other media types are only checked for
+// being declared by the operation.
+func (o *Operation) ValidateResponse(status int, contentType string, body []byte) error {
+	mediaType := parseMediaType(contentType)
+	schema, err := o.ResponseSchema(status, mediaType)
+	if err != nil {
+		return err
+	}
+	return validateBody(schema, mediaType, body)
+}
+
+// ValidateRequest validates the provided request, whose body has already been read, against this operation. The request
+// must have the operation's method, and its path must match the operation's path template. Path, query, and header
+// parameters are validated against their schemas, coerced from strings to numbers or booleans where their schemas
+// require it. JSON bodies are validated against the schema of the operation's request body.
+func (o *Operation) ValidateRequest(req *http.Request, body []byte) error {
+	if req.Method != o.Method {
+		return fmt.Errorf("method %s does not match operation %s method %s", req.Method, o.ID, o.Method)
+	}
+	pathParams, ok := matchPath(o.Path, req.URL.Path)
+	if !ok {
+		return fmt.Errorf("path %s does not match operation %s path %s", req.URL.Path, o.ID, o.Path)
+	}
+	for _, p := range o.parameters() {
+		var vals []string
+		switch p.in {
+		case "path":
+			if v, ok := pathParams[p.name]; ok {
+				vals = []string{v}
+			}
+		case "query":
+			vals = req.URL.Query()[p.name]
+		case "header":
+			vals = req.Header.Values(p.name)
+		default:
+			continue // cookie parameters are not validated.
+		}
+		if len(vals) == 0 {
+			if p.required || p.in == "path" {
+				return fmt.Errorf("missing required %s parameter %q", p.in, p.name)
+			}
+			continue
+		}
+		if p.schema == nil {
+			continue
+		}
+		if err := validateParam(p.schema, vals); err != nil {
+			return fmt.Errorf("invalid %s parameter %q: %w", p.in, p.name, err)
+		}
+	}
+	reqBody, ok := o.node["requestBody"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	reqBody, pointer := o.spec.deref(reqBody, o.pointer+"/requestBody")
+	if len(body) == 0 {
+		if required, _ := reqBody["required"].(bool); required {
+			return fmt.Errorf("missing required request body")
+		}
+		return nil
+	}
+	mediaType := parseMediaType(req.Header.Get("Content-Type"))
+	schema, err := o.contentSchema(reqBody, pointer, mediaType)
+	if err != nil {
+		return err
+	}
+	if err := validateBody(schema, mediaType, body); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+// parameter is a Parameter Object declared by an operation or its path.
+type parameter struct {
+	name     string
+	in       string
+	required bool
+	schema   *jsonschema.Schema
+}
+
+// parameters returns the parameters declared by this operation, including those declared by its path which are not
+// overridden by the operation.
+func (o *Operation) parameters() []parameter {
+	type key struct{ name, in string }
+	var (
+		result []parameter
+		index  = make(map[key]int)
+	)
+	pathPointer := strings.TrimSuffix(o.pointer, "/"+strings.ToLower(o.Method))
+	for _, src := range []struct {
+		node    map[string]interface{}
+		pointer string
+	}{{o.pathItem, pathPointer}, {o.node, o.pointer}} {
+		params, _ := src.node["parameters"].([]interface{})
+		for i, raw := range params {
+			node, ok := raw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			node, pointer := o.spec.deref(node, src.pointer+"/parameters/"+strconv.Itoa(i))
+			p := parameter{}
+			p.name, _ = node["name"].(string)
+			p.in, _ = node["in"].(string)
+			p.required, _ = node["required"].(bool)
+			if _, ok := node["schema"]; ok {
+				if schema, err := jsonschema.Compile(o.spec.doc, pointer+"/schema"); err == nil {
+					p.schema = schema
+				}
+			}
+			k := key{name: p.name, in: p.in}
+			if j, ok := index[k]; ok {
+				result[j] = p
+				continue
+			}
+			index[k] = len(result)
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// validateParam validates the values of a parameter against its schema. Since parameter values are strings, the values
+// are also tried as numbers and booleans, and as arrays of either, which may be sent as repeated or comma-separated
+// values. The parameter is valid if any of these interpretations is.
+func validateParam(schema *jsonschema.Schema, vals []string) error {
+	items := vals
+	if len(vals) == 1 {
+		items = strings.Split(vals[0], ",")
+	}
+	var (
+		strs    = make([]interface{}, len(items))
+		decoded = make([]interface{}, len(items))
+	)
+	for i, item := range items {
+		strs[i], decoded[i] = item, decodeScalar(item)
+	}
+	firstErr := schema.Validate(vals[0])
+	if firstErr == nil {
+		return nil
+	}
+	for _, candidate := range []interface{}{decodeScalar(vals[0]), strs, decoded} {
+		if schema.Validate(candidate) == nil {
+			return nil
+		}
+	}
+	return firstErr
+}
+
+// decodeScalar returns the provided string as a number or boolean, if it is one, or as a string otherwise.
+func decodeScalar(s string) interface{} {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	if s == "true" || s == "false" {
+		return s == "true"
+	}
+	return s
+}
+
+// matchPath matches the provided request path against an OpenAPI path template, like "/pets/{petId}", returning the
+// values of each templated parameter.
+func matchPath(template, path string) (map[string]string, bool) {
+	tmplSegs := strings.Split(strings.Trim(template, "/"), "/")
+	pathSegs := strings.Split(strings.Trim(path, "/"), "/")
+	if len(tmplSegs) != len(pathSegs) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, seg := range tmplSegs {
+		var (
+			pattern strings.Builder
+			names   []string
+			rest    = seg
+		)
+		pattern.WriteString("^")
+		for {
+			start := strings.IndexByte(rest, '{')
+			end := strings.IndexByte(rest, '}')
+			if start < 0 || end < start {
+				pattern.WriteString(regexp.QuoteMeta(rest))
+				break
+			}
+			pattern.WriteString(regexp.QuoteMeta(rest[:start]))
+			pattern.WriteString("([^/]+?)")
+			names = append(names, rest[start+1:end])
+			rest = rest[end+1:]
+		}
+		pattern.WriteString("$")
+		m := regexp.MustCompile(pattern.String()).FindStringSubmatch(pathSegs[i])
+		if m == nil {
+			return nil, false
+		}
+		for j, name := range names {
+			v, err := url.PathUnescape(m[j+1])
+			if err != nil {
+				v = m[j+1]
+			}
+			params[name] = v
+		}
+	}
+	return params, true
+}
+
+// contentSchema returns the schema for the provided media type in the content of a Response or Request Body Object,
+// found at the provided JSON pointer.
+func (o *Operation) contentSchema(node map[string]interface{}, pointer, mediaType string) (*jsonschema.Schema, error) {
+	node, pointer = o.spec.deref(node, pointer)
+	content, ok := node["content"].(map[string]interface{})
+	if !ok || len(content) == 0 {
+		return nil, nil
+	}
+	candidates := []string{mediaType, "*/*"}
+	if i := strings.IndexByte(mediaType, '/'); i >= 0 {
+		candidates = []string{mediaType, mediaType[:i] + "/*", "*/*"}
+	}
+	for _, mt := range candidates {
+		media, ok := content[mt].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, ok := media["schema"]; !ok {
+			return nil, nil
+		}
+		return jsonschema.Compile(o.spec.doc, pointer+"/content/"+escapePointer(mt)+"/schema")
+	}
+	return nil, fmt.Errorf("operation %s declares no content with media type %q", o.ID, mediaType)
+}
+
+// deref follows a Reference Object, like those commonly found in responses or request bodies, returning the referenced
+// node and its JSON pointer. Other nodes are returned unchanged.
+func (s *Spec) deref(node map[string]interface{}, pointer string) (map[string]interface{}, string) {
+	ref, ok := node["$ref"].(string)
+	if !ok || !strings.HasPrefix(ref, "#/") {
+		return node, pointer
+	}
+	var curr interface{} = s.doc
+	for _, token := range strings.Split(ref[2:], "/") {
+		m, ok := curr.(map[string]interface{})
+		if !ok {
+			return node, pointer
+		}
+		curr = m[strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")]
+	}
+	target, ok := curr.(map[string]interface{})
+	if !ok {
+		return node, pointer
+	}
+	return target, ref[1:]
+}
+
+// validateBody validates body against schema, if it is JSON and there is a schema to validate against.
+func validateBody(schema *jsonschema.Schema, mediaType string, body []byte) error {
+	if schema == nil || !isJSON(mediaType) {
+		return nil
+	}
+	return schema.ValidateJSON(body)
+}
+
+// parseMediaType returns the media type of the provided Content-Type header, without parameters.
+func parseMediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mediaType
+}
+
+// isJSON returns true if the provided media type is JSON, like application/json or application/problem+json.
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// escapePointer escapes the provided string for use as a single token in a JSON pointer.
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
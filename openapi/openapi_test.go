@@ -0,0 +1,335 @@
+package openapi_test
+
+import (
+	"github.com/orkes-io/go-httpfixture/openapi"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const spec = `{
+  "openapi": "3.0.3",
+  "paths": {
+    "/pets": {
+      "post": {
+        "operationId": "createPet",
+        "requestBody": {"$ref": "#/components/requestBodies/Pet"},
+        "responses": {
+          "201": {"$ref": "#/components/responses/Pet"},
+          "4XX": {
+            "description": "client error",
+            "content": {"application/problem+json": {"schema": {"required": ["title"]}}}
+          },
+          "default": {"description": "other", "content": {"*/*": {}}}
+        }
+      }
+    },
+    "/pets/{petId}": {
+      "parameters": [{"name": "petId", "in": "path", "required": true, "schema": {"type": "integer"}}],
+      "get": {
+        "operationId": "getPet",
+        "parameters": [
+          {"$ref": "#/components/parameters/Limit"},
+          {"name": "X-Trace", "in": "header", "required": true, "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {"$ref": "#/components/responses/Pet"},
+          "202": {"description": "accepted"}
+        }
+      }
+    },
+    "/files/{name}.{ext}": {
+      "get": {"operationId": "getFile", "responses": {"default": {"description": "file"}}}
+    },
+    "/remote": {
+      "get": {
+        "operationId": "getRemote",
+        "responses": {
+          "200": {"description": "remote", "content": {"application/json": {"schema": {"$ref": "other.json#/Pet"}}}}
+        }
+      }
+    }
+  },
+  "components": {
+    "parameters": {
+      "Limit": {"name": "limit", "in": "query", "schema": {"type": "integer", "maximum": 10}}
+    },
+    "requestBodies": {
+      "Pet": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Pet"}}}}
+    },
+    "responses": {
+      "Pet": {"description": "a pet", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Pet"}}}}
+    },
+    "schemas": {
+      "Pet": {
+        "type": "object",
+        "required": ["name"],
+        "properties": {"name": {"type": "string"}, "owner": {"$ref": "#/components/schemas/Owner"}}
+      },
+      "Owner": {"type": "object", "required": ["id"], "properties": {"id": {"type": "integer"}}}
+    }
+  }
+}`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr string
+	}{
+		{name: "valid", spec: spec},
+		{name: "invalid JSON", spec: `{`, wantErr: "error parsing OpenAPI spec"},
+		{name: "missing version", spec: `{"paths":{}}`, wantErr: "missing openapi version field"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := openapi.Parse([]byte(tt.spec))
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("want error containing %q; got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestOperation(t *testing.T) {
+	s := mustParse(t)
+	tests := []struct {
+		name       string
+		id         string
+		wantMethod string
+		wantPath   string
+		wantStatus int
+		wantErr    string
+	}{
+		{name: "post", id: "createPet", wantMethod: http.MethodPost, wantPath: "/pets", wantStatus: 201},
+		{name: "lowest 2xx", id: "getPet", wantMethod: http.MethodGet, wantPath: "/pets/{petId}", wantStatus: 200},
+		{name: "no 2xx", id: "getFile", wantMethod: http.MethodGet, wantPath: "/files/{name}.{ext}", wantStatus: 200},
+		{name: "missing", id: "deletePet", wantErr: `no operation with operationId "deletePet"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, err := s.Operation(tt.id)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("want error containing %q; got: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if op.Method != tt.wantMethod || op.Path != tt.wantPath {
+				t.Fatalf("want operation: %s %s; got: %s %s", tt.wantMethod, tt.wantPath, op.Method, op.Path)
+			}
+			if status := op.SuccessStatus(); status != tt.wantStatus {
+				t.Fatalf("want success status: %d; got: %d", tt.wantStatus, status)
+			}
+		})
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	s := mustParse(t)
+	tests := []struct {
+		name        string
+		id          string
+		status      int
+		contentType string
+		body        string
+		wantErr     string
+	}{
+		{name: "ref response", id: "getPet", status: 200, contentType: "application/json", body: `{"name":"rex"}`},
+		{
+			name:        "ref response mismatch",
+			id:          "getPet",
+			status:      200,
+			contentType: "application/json; charset=utf-8",
+			body:        `{"name":1}`,
+			wantErr:     "$.name",
+		},
+		{
+			name:        "nested ref",
+			id:          "createPet",
+			status:      201,
+			contentType: "application/json",
+			body:        `{"name":"rex","owner":{}}`,
+			wantErr:     `$.owner: missing required property "id"`,
+		},
+		{
+			name:        "status range",
+			id:          "createPet",
+			status:      409,
+			contentType: "application/problem+json",
+			body:        `{}`,
+			wantErr:     `missing required property "title"`,
+		},
+		{name: "default response", id: "createPet", status: 500, contentType: "text/plain", body: "oops"},
+		{name: "no content", id: "getPet", status: 202, contentType: "application/json", body: `not json`},
+		{name: "undeclared status", id: "getPet", status: 404, wantErr: "declares no response with status 404"},
+		{
+			name:        "undeclared media type",
+			id:          "getPet",
+			status:      200,
+			contentType: "text/plain",
+			wantErr:     `declares no content with media type "text/plain"`,
+		},
+		{
+			name:        "remote ref",
+			id:          "getRemote",
+			status:      200,
+			contentType: "application/json",
+			body:        `{}`,
+			wantErr:     "unsupported non-local reference",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, err := s.Operation(tt.id)
+			if err != nil {
+				t.Fatalf("error looking up operation: %v", err)
+			}
+			err = op.ValidateResponse(tt.status, tt.contentType, []byte(tt.body))
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("want error containing %q; got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	s := mustParse(t)
+	tests := []struct {
+		name    string
+		id      string
+		method  string
+		target  string
+		header  http.Header
+		body    string
+		wantErr string
+	}{
+		{
+			name:   "path template",
+			id:     "getPet",
+			method: http.MethodGet,
+			target: "/pets/42?limit=5",
+			header: http.Header{"X-Trace": {"abc"}},
+		},
+		{
+			name:    "path parameter type",
+			id:      "getPet",
+			method:  http.MethodGet,
+			target:  "/pets/rex",
+			header:  http.Header{"X-Trace": {"abc"}},
+			wantErr: `invalid path parameter "petId"`,
+		},
+		{
+			name:    "path segment count",
+			id:      "getPet",
+			method:  http.MethodGet,
+			target:  "/pets/42/toys",
+			wantErr: "does not match operation getPet path /pets/{petId}",
+		},
+		{
+			name:    "ref query parameter",
+			id:      "getPet",
+			method:  http.MethodGet,
+			target:  "/pets/42?limit=50",
+			header:  http.Header{"X-Trace": {"abc"}},
+			wantErr: `invalid query parameter "limit"`,
+		},
+		{
+			name:    "missing header",
+			id:      "getPet",
+			method:  http.MethodGet,
+			target:  "/pets/42",
+			wantErr: `missing required header parameter "X-Trace"`,
+		},
+		{
+			name:    "method mismatch",
+			id:      "getPet",
+			method:  http.MethodDelete,
+			target:  "/pets/42",
+			wantErr: "method DELETE does not match operation getPet method GET",
+		},
+		{name: "multiple parameters in segment", id: "getFile", method: http.MethodGet, target: "/files/report.tar.gz"},
+		{
+			name:    "multiple parameters mismatch",
+			id:      "getFile",
+			method:  http.MethodGet,
+			target:  "/files/report",
+			wantErr: "does not match operation getFile",
+		},
+		{
+			name:   "ref request body",
+			id:     "createPet",
+			method: http.MethodPost,
+			target: "/pets",
+			header: http.Header{"Content-Type": {"application/json"}},
+			body:   `{"name":"rex"}`,
+		},
+		{
+			name:    "ref request body mismatch",
+			id:      "createPet",
+			method:  http.MethodPost,
+			target:  "/pets",
+			header:  http.Header{"Content-Type": {"application/json"}},
+			body:    `{"owner":{"id":1}}`,
+			wantErr: `invalid request body: $: missing required property "name"`,
+		},
+		{
+			name:    "missing required body",
+			id:      "createPet",
+			method:  http.MethodPost,
+			target:  "/pets",
+			wantErr: "missing required request body",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, err := s.Operation(tt.id)
+			if err != nil {
+				t.Fatalf("error looking up operation: %v", err)
+			}
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			for k, vs := range tt.header {
+				req.Header[k] = vs
+			}
+			err = op.ValidateRequest(req, []byte(tt.body))
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("want error containing %q; got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	s, err := openapi.Load("../testdata/petstore.json")
+	if err != nil {
+		t.Fatalf("error loading spec: %v", err)
+	}
+	if s == nil {
+		t.Fatalf("want spec; got nil")
+	}
+	if _, err := openapi.Load("../testdata/missing.json"); err == nil || !strings.Contains(err.Error(), "error reading") {
+		t.Fatalf("want error reading spec; got: %v", err)
+	}
+}
+
+func mustParse(t *testing.T) *openapi.Spec {
+	t.Helper()
+	s, err := openapi.Parse([]byte(spec))
+	if err != nil {
+		t.Fatalf("error parsing spec: %v", err)
+	}
+	return s
+}
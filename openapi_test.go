@@ -0,0 +1,149 @@
+package httpfixture_test
+
+import (
+	"github.com/orkes-io/go-httpfixture"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPIResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		operationID string
+		body        string
+		wantPanic   string
+	}{
+		{
+			name:        "conforming",
+			operationID: "getPet",
+			body:        `{"id":1,"name":"Rex","tag":null}`,
+		},
+		{
+			name:        "conforming array",
+			operationID: "listPets",
+			body:        `[{"id":1,"name":"Rex"},{"id":2,"name":"Tom","tag":"cat"}]`,
+		},
+		{
+			name:        "missing required property",
+			operationID: "getPet",
+			body:        `{"name":"Rex"}`,
+			wantPanic:   `missing required property "id"`,
+		},
+		{
+			name:        "wrong type",
+			operationID: "listPets",
+			body:        `[{"id":"one","name":"Rex"}]`,
+			wantPanic:   "$[0].id",
+		},
+		{
+			name:        "unknown operation",
+			operationID: "deletePet",
+			body:        `{}`,
+			wantPanic:   "deletePet",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				r := recover()
+				if tt.wantPanic == "" && r != nil {
+					t.Fatalf("unexpected panic: %v", r)
+				}
+				if tt.wantPanic != "" {
+					err, ok := r.(error)
+					if !ok || !strings.Contains(err.Error(), tt.wantPanic) {
+						t.Fatalf("want panic containing %q; got: %v", tt.wantPanic, r)
+					}
+				}
+			}()
+			f := httpfixture.OpenAPIResponse("testdata/petstore.json", tt.operationID, tt.body)
+
+			s := httpfixture.NewServer(f)
+			s.Start(t)
+			defer s.Close()
+			resp, err := http.Get(s.URL() + "/pets/1")
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("want statusCode: %d; got: %d", http.StatusOK, resp.StatusCode)
+			}
+			if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+				t.Fatalf("want Content-Type: application/json; got: %s", ct)
+			}
+			if body := string(must(io.ReadAll(resp.Body))); body != tt.body {
+				t.Fatalf("want body: '%s'; got: '%s'", tt.body, body)
+			}
+		})
+	}
+}
+
+func TestAssertOpenAPIRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		operationID string
+		req         *http.Request
+		wantFailure bool
+	}{
+		{
+			name:        "valid body",
+			operationID: "createPet",
+			req: withHeader(httptest.NewRequest(http.MethodPost, "/pets", strings.NewReader(`{"name":"Rex","tag":"dog"}`)),
+				"Content-Type", "application/json"),
+		},
+		{
+			name:        "invalid body",
+			operationID: "createPet",
+			req: withHeader(httptest.NewRequest(http.MethodPost, "/pets", strings.NewReader(`{"name":""}`)),
+				"Content-Type", "application/json"),
+			wantFailure: true,
+		},
+		{
+			name:        "missing required body",
+			operationID: "createPet",
+			req:         httptest.NewRequest(http.MethodPost, "/pets", nil),
+			wantFailure: true,
+		},
+		{
+			name:        "valid path parameter",
+			operationID: "getPet",
+			req:         httptest.NewRequest(http.MethodGet, "/pets/42", nil),
+		},
+		{
+			name:        "invalid path parameter",
+			operationID: "getPet",
+			req:         httptest.NewRequest(http.MethodGet, "/pets/rex", nil),
+			wantFailure: true,
+		},
+		{
+			name:        "valid query parameter",
+			operationID: "listPets",
+			req:         httptest.NewRequest(http.MethodGet, "/pets?limit=10", nil),
+		},
+		{
+			name:        "invalid query parameter",
+			operationID: "listPets",
+			req:         httptest.NewRequest(http.MethodGet, "/pets?limit=0", nil),
+			wantFailure: true,
+		},
+		{
+			name:        "wrong method",
+			operationID: "listPets",
+			req:         httptest.NewRequest(http.MethodDelete, "/pets", nil),
+			wantFailure: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testT := &testing.T{}
+			f := httpfixture.OK("/pets", "", httpfixture.AssertOpenAPIRequest("testdata/petstore.json", tt.operationID))
+			_ = f.Run(testT, tt.req)
+			if tt.wantFailure != testT.Failed() {
+				t.Fatalf("unexpected failure reported; want: %t; got: %t", tt.wantFailure, testT.Failed())
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package httpfixture
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// WeightedFixture is a fixture which is chosen by Random with probability proportional to its weight.
+type WeightedFixture struct {
+	Weight int
+	F      F
+}
+
+// Random returns a fixture which delegates each request to one of the provided choices, selected at random with
+// probability proportional to each choice's weight; e.g. weights of 9 and 1 select the first choice for roughly 90% of
+// requests. Choices are selected using a randomly seeded source, unless a seed is provided in opts using WithSeed.
+//
+// As with Seq, the routes and methods of sub-fixtures are ignored. This func panics if any weight is negative, or if no
+// weight is positive.
+func Random(route, method string, choices []WeightedFixture, opts ...FixtureOpt) F {
+	var total int
+	for _, c := range choices {
+		if c.Weight < 0 {
+			panic("httpfixture: negative weight passed to Random")
+		}
+		total += c.Weight
+	}
+	if total == 0 {
+		panic("httpfixture: Random requires at least one choice with positive weight")
+	}
+	rf := &randomFixture{
+		choices:     choices,
+		total:       total,
+		baseFixture: base(route, method, 0, opts...),
+	}
+	if rf.rng == nil {
+		rf.rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return rf
+}
+
+// RandomBody returns a fixture which responds to matching requests with status 200 OK and a body chosen uniformly at
+// random from the provided bodies, using a randomly seeded source. Use RandomBodyOpts to seed the source using
+// WithSeed, or to provide other options. This func panics if no bodies are provided.
+func RandomBody(route, method string, bodies ...[]byte) F {
+	return RandomBodyOpts(route, method, bodies)
+}
+
+// RandomBodyOpts returns a fixture which responds with a random choice of the provided bodies, as in RandomBody.
+func RandomBodyOpts(route, method string, bodies [][]byte, opts ...FixtureOpt) F {
+	if len(bodies) == 0 {
+		panic("httpfixture: RandomBody requires at least one body")
+	}
+	bf := base(route, method, 0, opts...)
+	rng := bf.rng
+	if rng == nil {
+		rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	var mu sync.Mutex // guards rng, which is not safe for concurrent use.
+	return &funcFixture{
+		fn: func(req *http.Request) (int, []byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			return http.StatusOK, bodies[rng.Intn(len(bodies))]
+		},
+		baseFixture: bf,
+	}
+}
+
+// WithSeed seeds the random source used by a fixture which makes random choices, so that its responses are
+// deterministic.
+func WithSeed(seed int64) FixtureOpt {
+	return func(f *baseFixture) {
+		f.rng = rand.New(rand.NewSource(seed))
+	}
+}
+
+// HashedBody returns a fixture which responds to matching requests with status 200 OK and a body of size pseudo-random
+// bytes. The bytes are generated from a source seeded by the request path, so the same path always receives the same
+// body, and different paths receive different bodies.
+func HashedBody(route, method string, size int, opts ...FixtureOpt) F {
+	return &funcFixture{
+		fn: func(req *http.Request) (int, []byte) {
+			h := fnv.New64a()
+			_, _ = h.Write([]byte(req.URL.Path))
+			body := make([]byte, size)
+			_, _ = rand.New(rand.NewSource(int64(h.Sum64()))).Read(body)
+			return http.StatusOK, body
+		},
+		baseFixture: base(route, method, 0, opts...),
+	}
+}
+
+// randomFixture delegates each request to a randomly chosen sub-fixture.
+type randomFixture struct {
+	choices []WeightedFixture
+	total   int
+
+	mu sync.Mutex // guards rng, which is not safe for concurrent use.
+	baseFixture
+}
+
+// Run exchanges the provided request for an appropriate response.
+func (rf *randomFixture) Run(t testing.TB, req *http.Request) *http.Response {
+	t.Helper()
+	rf.baseFixture.assertAll(t, req)
+	rf.mu.Lock()
+	n := rf.rng.Intn(rf.total)
+	rf.mu.Unlock()
+	last := len(rf.choices) - 1
+	for _, c := range rf.choices[:last] {
+		if n < c.Weight {
+			return c.F.Run(t, req)
+		}
+		n -= c.Weight
+	}
+	return rf.choices[last].F.Run(t, req) // n < total, so n falls within the last weight if no earlier one.
+}
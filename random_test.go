@@ -0,0 +1,85 @@
+package httpfixture_test
+
+import (
+	"bytes"
+	"github.com/orkes-io/go-httpfixture"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRandom(t *testing.T) {
+	f := httpfixture.Random("/flaky", http.MethodGet, []httpfixture.WeightedFixture{
+		{Weight: 9, F: httpfixture.GetOK("", "ok")},
+		{Weight: 1, F: httpfixture.ResponseCode("", "", http.StatusInternalServerError)},
+	}, httpfixture.WithSeed(42))
+
+	const n = 10000
+	counts := make(map[int]int)
+	for i := 0; i < n; i++ {
+		resp := f.Run(t, httptest.NewRequest(http.MethodGet, "/flaky", nil))
+		counts[resp.StatusCode]++
+	}
+	if counts[http.StatusOK]+counts[http.StatusInternalServerError] != n {
+		t.Fatalf("want only 200 and 500 responses; got: %v", counts)
+	}
+	if got := counts[http.StatusInternalServerError]; got < 900 || got > 1100 {
+		t.Fatalf("want roughly %d 500 responses; got: %d", n/10, got)
+	}
+}
+
+func TestRandomBody(t *testing.T) {
+	bodies := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	sequence := func(f httpfixture.F) string {
+		var result []byte
+		for i := 0; i < 30; i++ {
+			resp := f.Run(t, httptest.NewRequest(http.MethodGet, "/quote", nil))
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("want statusCode: %d; got: %d", http.StatusOK, resp.StatusCode)
+			}
+			b, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("error reading body: %v", err)
+			}
+			result = append(result, b...)
+		}
+		return string(result)
+	}
+
+	first := sequence(httpfixture.RandomBodyOpts("/quote", http.MethodGet, bodies, httpfixture.WithSeed(7)))
+	second := sequence(httpfixture.RandomBodyOpts("/quote", http.MethodGet, bodies, httpfixture.WithSeed(7)))
+	if first != second {
+		t.Fatalf("want the same sequence for the same seed; got: %s and %s", first, second)
+	}
+	for _, b := range bodies {
+		if !strings.Contains(first, string(b)) {
+			t.Fatalf("want every body to be chosen; got sequence: %s", first)
+		}
+	}
+}
+
+func TestHashedBody(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.HashedBody("/blobs", http.MethodGet, 64))
+	s.Start(t)
+	defer s.Close()
+
+	get := func(path string) []byte {
+		resp, err := http.Get(s.URL() + path)
+		if err != nil {
+			t.Fatalf("error making request: %v", err)
+		}
+		body := must(io.ReadAll(resp.Body))
+		if len(body) != 64 {
+			t.Fatalf("want body of 64 bytes; got: %d", len(body))
+		}
+		return body
+	}
+	if a, b := get("/blobs/1"), get("/blobs/1"); !bytes.Equal(a, b) {
+		t.Fatalf("want the same body for the same path; got: %x and %x", a, b)
+	}
+	if a, b := get("/blobs/1"), get("/blobs/2"); bytes.Equal(a, b) {
+		t.Fatalf("want different bodies for different paths; got: %x for both", a)
+	}
+}
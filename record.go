@@ -0,0 +1,281 @@
+package httpfixture
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// NewRecordReplayServer creates a new httpfixture.Server for snapshot testing. If no recording exists at the provided
+// file, the server runs in record mode: every request is forwarded to the upstream base URL, and the upstream response
+// is returned to the client and recorded. The recording is saved to file when the server is closed. If a recording
+// already exists, the server runs in replay mode, responding to requests using recorded responses without contacting
+// upstream.
+//
+// Redirects sent by upstream are recorded and returned as-is, rather than followed. In replay mode, recorded responses
+// are matched by method and path; the query string is ignored. If the same method and path were recorded more than
+// once, the recorded responses are replayed in order, as in Seq.
+//
+// This func panics if upstream is not a valid URL, or if a recording exists but cannot be read.
+func NewRecordReplayServer(upstream, file string) *Server {
+	b, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		u, err := url.Parse(upstream)
+		if err != nil {
+			panic(fmt.Errorf("error parsing upstream URL: %w", err))
+		}
+		rf := &recordFixture{
+			upstream:    u,
+			baseFixture: base("/", "*", 0),
+		}
+		s := NewServer(rf)
+		s.recorder = rf
+		s.recordFile = file
+		return s
+	}
+	if err != nil {
+		panic(fmt.Errorf("error reading recording: %w", err))
+	}
+	var rec recording
+	if err := json.Unmarshal(b, &rec); err != nil {
+		panic(fmt.Errorf("error parsing recording: %w", err))
+	}
+	return NewServer(rec.fixtures()...)
+}
+
+// Proxy returns a fixture which forwards matching requests to the upstream base URL, after running its assertions and
+// waiting for any configured delay, and responds with the upstream response. The upstream response body is streamed to
+// the client, and hop-by-hop headers are not forwarded in either direction. Headers set on the fixture, such as by
+// WithSetCookie, replace upstream headers with the same key.
+//
+// Proxy fixtures make real network requests to upstream. Redirects sent by upstream are relayed to the client rather than
+// followed. This func panics if upstream is not a valid URL.
+func Proxy(route, method string, upstreamBaseURL string, opts ...FixtureOpt) F {
+	u, err := url.Parse(upstreamBaseURL)
+	if err != nil {
+		panic(fmt.Errorf("error parsing upstream URL: %w", err))
+	}
+	return &proxyFixture{
+		upstream:    u,
+		baseFixture: base(route, method, 0, opts...),
+	}
+}
+
+// proxyFixture forwards all requests to an upstream server.
+type proxyFixture struct {
+	upstream *url.URL
+	baseFixture
+}
+
+// Run exchanges the provided request for an appropriate response.
+func (pf *proxyFixture) Run(t testing.TB, req *http.Request) *http.Response {
+	t.Helper()
+	resp := pf.baseFixture.respond(t, req)
+	if resp == nil {
+		return nil
+	}
+	upstream, err := forwardStream(upstreamClient, pf.upstream, req)
+	if err != nil {
+		t.Logf("error forwarding request to upstream: %v", err)
+		t.Fail()
+		return &http.Response{StatusCode: http.StatusBadGateway}
+	}
+	for key, vals := range resp.Header {
+		upstream.Header[key] = vals
+	}
+	upstream.Trailer = resp.Trailer
+	upstream.TransferEncoding = resp.TransferEncoding
+	return upstream
+}
+
+// recording is the on-disk format of exchanges recorded by a record/replay Server.
+type recording struct {
+	Entries []recordedEntry `json:"entries"`
+}
+
+// recordedEntry is a single recorded exchange.
+type recordedEntry struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Query  string      `json:"query,omitempty"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+// fixtures creates fixtures which replay this recording. Fixtures are ordered by decreasing path length, so that they
+// are listed from most to least specific; the order does not affect matching, which always prefers the longest route.
+func (r recording) fixtures() []F {
+	type key struct{ method, path string }
+	var keys []key
+	byKey := make(map[key][]F)
+	for _, e := range r.Entries {
+		k := key{method: e.Method, path: e.Path}
+		if _, ok := byKey[k]; !ok {
+			keys = append(keys, k)
+		}
+		byKey[k] = append(byKey[k], Bytes(e.Path, e.Method, e.Status, e.Body, withHeader(e.Header)))
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return len(standardizePath(keys[i].path)) > len(standardizePath(keys[j].path))
+	})
+	result := make([]F, 0, len(keys))
+	for _, k := range keys {
+		fixtures := byKey[k]
+		if len(fixtures) == 1 {
+			result = append(result, fixtures[0])
+			continue
+		}
+		result = append(result, Seq(k.path, k.method, fixtures...))
+	}
+	return result
+}
+
+// withHeader sets all the provided headers on responses from a fixture.
+func withHeader(h http.Header) FixtureOpt {
+	return func(f *baseFixture) {
+		if f.header == nil {
+			f.header = make(http.Header)
+		}
+		for key, vals := range h {
+			for _, v := range vals {
+				f.header.Add(key, v)
+			}
+		}
+	}
+}
+
+// recordFixture forwards all requests to an upstream server, recording each exchange.
+type recordFixture struct {
+	upstream *url.URL
+
+	mu      sync.Mutex
+	entries []recordedEntry
+	baseFixture
+}
+
+// Run exchanges the provided request for an appropriate response.
+func (rf *recordFixture) Run(t testing.TB, req *http.Request) *http.Response {
+	t.Helper()
+	rf.baseFixture.assertAll(t, req)
+	resp, body, err := forward(upstreamClient, rf.upstream, req)
+	if err != nil {
+		t.Logf("error forwarding request to upstream: %v", err)
+		t.Fail()
+		return &http.Response{StatusCode: http.StatusBadGateway}
+	}
+	rf.mu.Lock()
+	rf.entries = append(rf.entries, recordedEntry{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  req.URL.RawQuery,
+		Status: resp.StatusCode,
+		Header: resp.Header,
+		Body:   body,
+	})
+	rf.mu.Unlock()
+	return resp
+}
+
+// save writes all exchanges recorded so far to the provided file.
+func (rf *recordFixture) save(file string) error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	b, err := json.MarshalIndent(recording{Entries: rf.entries}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, b, 0o644)
+}
+
+// hopHeaders are hop-by-hop headers, which are not forwarded to or from upstream.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// upstreamClient is used to forward requests upstream. It does not follow redirects, so that upstream responses are
+// relayed as-is.
+var upstreamClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// forward sends a copy of the provided request to the upstream base URL, returning the upstream response along with
+// its body, which is read into memory. The returned response's body reads from a copy of the returned body.
+func forward(client *http.Client, upstream *url.URL, req *http.Request) (*http.Response, []byte, error) {
+	resp, err := forwardStream(client, upstream, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading upstream response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, body, nil
+}
+
+// forwardStream sends a copy of the provided request to the upstream base URL, returning the upstream response. The
+// caller must close the body of the returned response, which reads directly from upstream.
+func forwardStream(client *http.Client, upstream *url.URL, req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(req)
+	if err != nil {
+		return nil, fmt.Errorf("error reading request body: %w", err)
+	}
+	u := *upstream
+	u.Path = singleJoiningSlash(upstream.Path, req.URL.Path)
+	u.RawQuery = req.URL.RawQuery
+	out, err := http.NewRequestWithContext(req.Context(), req.Method, u.String(), bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	out.Header = req.Header.Clone()
+	for _, h := range hopHeaders {
+		out.Header.Del(h)
+	}
+	resp, err := client.Do(out)
+	if err != nil {
+		return nil, err
+	}
+	header := resp.Header.Clone()
+	for _, h := range hopHeaders {
+		header.Del(h)
+	}
+	return &http.Response{
+		StatusCode: resp.StatusCode,
+		Header:     header,
+		Body:       resp.Body,
+	}, nil
+}
+
+// singleJoiningSlash joins a and b with exactly one slash between them.
+func singleJoiningSlash(a, b string) string {
+	aslash := len(a) > 0 && a[len(a)-1] == '/'
+	bslash := len(b) > 0 && b[0] == '/'
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
@@ -0,0 +1,165 @@
+package httpfixture_test
+
+import (
+	"github.com/orkes-io/go-httpfixture"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordReplayServer(t *testing.T) {
+	var upstreamCalls int
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		upstreamCalls++
+		rw.Header().Set("X-Upstream", "real")
+		rw.WriteHeader(http.StatusCreated)
+		_, _ = io.WriteString(rw, "upstream says "+req.URL.Path)
+	}))
+	defer upstream.Close()
+
+	file := filepath.Join(t.TempDir(), "recording.json")
+	exchange := func(t *testing.T, s *httpfixture.Server) {
+		t.Helper()
+		resp, err := http.Get(s.URL() + "/api/users")
+		if err != nil {
+			t.Fatalf("error making request: %v", err)
+		}
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("want statusCode: %d; got: %d", http.StatusCreated, resp.StatusCode)
+		}
+		if got := resp.Header.Get("X-Upstream"); got != "real" {
+			t.Fatalf("want X-Upstream header: 'real'; got: '%s'", got)
+		}
+		if body := string(must(io.ReadAll(resp.Body))); body != "upstream says /api/users" {
+			t.Fatalf("unexpected body: '%s'", body)
+		}
+	}
+
+	t.Run("record", func(t *testing.T) {
+		s := httpfixture.NewRecordReplayServer(upstream.URL, file)
+		s.Start(t)
+		exchange(t, s)
+		s.Close()
+		if upstreamCalls != 1 {
+			t.Fatalf("want 1 upstream call; got: %d", upstreamCalls)
+		}
+		if _, err := os.Stat(file); err != nil {
+			t.Fatalf("recording was not saved: %v", err)
+		}
+	})
+	t.Run("replay", func(t *testing.T) {
+		s := httpfixture.NewRecordReplayServer(upstream.URL, file)
+		s.Start(t)
+		defer s.Close()
+		exchange(t, s)
+		if upstreamCalls != 1 {
+			t.Fatalf("upstream was called during replay; calls: %d", upstreamCalls)
+		}
+	})
+}
+
+func TestRecordReplayServerRedirect(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/old" {
+			http.Redirect(rw, req, "/new", http.StatusFound)
+			return
+		}
+		_, _ = io.WriteString(rw, "new")
+	}))
+	defer upstream.Close()
+
+	file := filepath.Join(t.TempDir(), "recording.json")
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	for _, mode := range []string{"record", "replay"} {
+		t.Run(mode, func(t *testing.T) {
+			s := httpfixture.NewRecordReplayServer(upstream.URL, file)
+			s.Start(t)
+			resp, err := client.Get(s.URL() + "/old")
+			s.Close()
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if resp.StatusCode != http.StatusFound {
+				t.Fatalf("want statusCode: %d; got: %d", http.StatusFound, resp.StatusCode)
+			}
+			if loc := resp.Header.Get("Location"); loc != "/new" {
+				t.Fatalf("want Location: '/new'; got: '%s'", loc)
+			}
+		})
+	}
+}
+
+func TestProxy(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Upstream", "real")
+		rw.Header().Set("X-Seen-Auth", req.Header.Get("Authorization"))
+		rw.WriteHeader(http.StatusCreated)
+		_, _ = io.WriteString(rw, req.Method+" "+req.URL.RequestURI()+" "+string(must(io.ReadAll(req.Body))))
+	}))
+	defer upstream.Close()
+
+	mockT := &testing.T{}
+	s := httpfixture.NewServer(
+		httpfixture.Proxy("/api", http.MethodPost, upstream.URL+"/v1", httpfixture.AssertBodyContains("payload")),
+		httpfixture.GetOK("/local", "fixture"),
+	)
+	s.Start(mockT)
+	defer s.Close()
+
+	req := must(http.NewRequest(http.MethodPost, s.URL()+"/api/users?page=2", strings.NewReader("payload")))
+	req.Header.Set("Authorization", "Bearer token")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("want statusCode: %d; got: %d", http.StatusCreated, resp.StatusCode)
+	}
+	if got := resp.Header.Get("X-Upstream"); got != "real" {
+		t.Fatalf("want X-Upstream header: 'real'; got: '%s'", got)
+	}
+	if got := resp.Header.Get("X-Seen-Auth"); got != "Bearer token" {
+		t.Fatalf("want Authorization header forwarded; upstream saw: '%s'", got)
+	}
+	if body := string(must(io.ReadAll(resp.Body))); body != "POST /v1/api/users?page=2 payload" {
+		t.Fatalf("unexpected body: '%s'", body)
+	}
+	if mockT.Failed() {
+		t.Fatalf("unexpected assertion failure: %v", s.AssertionFailures())
+	}
+}
+
+func TestProxyRedirect(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/old" {
+			http.Redirect(rw, req, "/new", http.StatusFound)
+			return
+		}
+		_, _ = io.WriteString(rw, "new")
+	}))
+	defer upstream.Close()
+
+	s := httpfixture.NewServer(httpfixture.Proxy("/old", http.MethodGet, upstream.URL))
+	s.Start(t)
+	defer s.Close()
+
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(s.URL() + "/old")
+	if err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("want statusCode: %d; got: %d", http.StatusFound, resp.StatusCode)
+	}
+	if loc := resp.Header.Get("Location"); loc != "/new" {
+		t.Fatalf("want Location: '/new'; got: '%s'", loc)
+	}
+}
@@ -0,0 +1,74 @@
+package httpfixture
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// SSE returns a fixture which responds to GET requests at the provided route with a stream of Server-Sent Events, and
+// status 200 OK. The response has the Content-Type text/event-stream, and each event is written verbatim followed by a
+// blank line, then flushed to the client; e.g. an event of "data: hello" is written as "data: hello\n\n". Events are
+// written back-to-back unless a delay between them is provided using WithEventInterval.
+func SSE(route string, events []string, opts ...FixtureOpt) F {
+	opts = append([]FixtureOpt{
+		withHeader(http.Header{"Content-Type": {"text/event-stream"}, "Cache-Control": {"no-cache"}}),
+		WithChunked(),
+	}, opts...)
+	return &sseFixture{
+		events:      events,
+		baseFixture: base(route, http.MethodGet, http.StatusOK, opts...),
+	}
+}
+
+// WithEventInterval sets the delay between events written by an SSE fixture.
+func WithEventInterval(d time.Duration) FixtureOpt {
+	return func(f *baseFixture) {
+		f.interval = d
+	}
+}
+
+// sseFixture streams a series of Server-Sent Events.
+type sseFixture struct {
+	events []string
+	baseFixture
+}
+
+// Run exchanges the provided request for an appropriate response.
+func (sf *sseFixture) Run(t testing.TB, req *http.Request) *http.Response {
+	t.Helper()
+	resp := sf.baseFixture.respond(t, req)
+	if resp == nil {
+		return nil
+	}
+	resp.Body = io.NopCloser(&eventReader{ctx: req.Context(), events: sf.events, interval: sf.interval})
+	return resp
+}
+
+// eventReader reads a series of events, each followed by a blank line, waiting for an interval between events. Each
+// call to Read returns bytes from at most one event, so that each event is flushed separately.
+type eventReader struct {
+	ctx      context.Context
+	events   []string
+	interval time.Duration
+	sent     int    // the number of events started.
+	pending  []byte // the unread remainder of the current event.
+}
+
+func (er *eventReader) Read(p []byte) (int, error) {
+	if len(er.pending) == 0 {
+		if er.sent == len(er.events) {
+			return 0, io.EOF
+		}
+		if er.sent > 0 && !sleep(er.ctx, er.interval) {
+			return 0, er.ctx.Err()
+		}
+		er.pending = []byte(er.events[er.sent] + "\n\n")
+		er.sent++
+	}
+	n := copy(p, er.pending)
+	er.pending = er.pending[n:]
+	return n, nil
+}
@@ -0,0 +1,56 @@
+package httpfixture_test
+
+import (
+	"bufio"
+	"github.com/orkes-io/go-httpfixture"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSSE(t *testing.T) {
+	events := []string{"data: first", "event: update\ndata: second", "data: third"}
+	s := httpfixture.NewServer(httpfixture.SSE("/events", events,
+		httpfixture.WithEventInterval(50*time.Millisecond)))
+	s.Start(t)
+	defer s.Close()
+
+	start := time.Now()
+	resp, err := http.Get(s.URL() + "/events")
+	if err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("want Content-Type: text/event-stream; got: %s", ct)
+	}
+
+	var (
+		got      []string
+		event    string
+		arrivals []time.Duration
+	)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			if event != "" {
+				event += "\n"
+			}
+			event += line
+			continue
+		}
+		got = append(got, event)
+		arrivals = append(arrivals, time.Since(start))
+		event = ""
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("error reading stream: %v", err)
+	}
+	if !reflect.DeepEqual(got, events) {
+		t.Fatalf("want events: %q; got: %q", events, got)
+	}
+	if gap := arrivals[len(arrivals)-1] - arrivals[0]; gap < 100*time.Millisecond {
+		t.Fatalf("want events spread over at least 100ms; got: %s", gap)
+	}
+}
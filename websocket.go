@@ -0,0 +1,108 @@
+package httpfixture
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// websocketGUID is appended to the client's key to compute the Sec-WebSocket-Accept header, per RFC 6455.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket returns a fixture which completes the WebSocket opening handshake for GET requests at the provided route,
+// then passes the hijacked connection to handler. The connection is closed after handler returns.
+//
+// Only the handshake is performed by this fixture: handler is responsible for reading and writing WebSocket frames on
+// the connection, e.g. using a WebSocket library which can wrap an existing net.Conn. This package depends only on
+// the standard library, which has no WebSocket implementation.
+//
+// The handshake request must include "Upgrade: websocket", "Connection: Upgrade", "Sec-WebSocket-Version: 13", and a
+// valid Sec-WebSocket-Key; requests which do not fail the test and receive 400 Bad Request. As with Hijack, WebSocket
+// fixtures do not work with StartHTTP2.
+func WebSocket(route string, handler func(conn net.Conn), opts ...FixtureOpt) F {
+	opts = append([]FixtureOpt{assertWebSocketHandshake()}, opts...)
+	return &websocketFixture{
+		handler:     handler,
+		baseFixture: base(route, http.MethodGet, 0, opts...),
+	}
+}
+
+// assertWebSocketHandshake asserts that requests are valid WebSocket opening handshakes.
+func assertWebSocketHandshake() FixtureOpt {
+	return func(f *baseFixture) {
+		f.assertions = append(f.assertions, checkWebSocketHandshake)
+	}
+}
+
+// websocketFixture completes the WebSocket opening handshake, and hands the connection to a handler.
+type websocketFixture struct {
+	handler func(conn net.Conn)
+	baseFixture
+}
+
+// Run exchanges the provided request for an appropriate response.
+func (wf *websocketFixture) Run(t testing.TB, req *http.Request) *http.Response {
+	t.Helper()
+	wf.baseFixture.assertAll(t, req)
+	if checkWebSocketHandshake(req) != nil {
+		return &http.Response{StatusCode: http.StatusBadRequest}
+	}
+	conn := hijack(t, req)
+	if conn == nil {
+		return nil
+	}
+	defer conn.Close()
+	_, err := fmt.Fprintf(conn, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: %s\r\n\r\n", websocketAccept(req.Header.Get("Sec-WebSocket-Key")))
+	if err != nil {
+		t.Logf("httpfixture: error completing WebSocket handshake: %v", err)
+		t.Fail()
+		return nil
+	}
+	wf.handler(conn)
+	return nil
+}
+
+// checkWebSocketHandshake returns an error if the provided request is not a valid WebSocket opening handshake.
+func checkWebSocketHandshake(req *http.Request) error {
+	if !headerContainsToken(req.Header, "Upgrade", "websocket") {
+		return errors.New("websocket handshake is missing Upgrade: websocket header")
+	}
+	if !headerContainsToken(req.Header, "Connection", "upgrade") {
+		return errors.New("websocket handshake is missing Connection: Upgrade header")
+	}
+	if v := req.Header.Get("Sec-WebSocket-Version"); v != "13" {
+		return fmt.Errorf("websocket handshake has Sec-WebSocket-Version %q; want 13", v)
+	}
+	key, err := base64.StdEncoding.DecodeString(req.Header.Get("Sec-WebSocket-Key"))
+	if err != nil || len(key) != 16 {
+		return fmt.Errorf("websocket handshake has invalid Sec-WebSocket-Key %q", req.Header.Get("Sec-WebSocket-Key"))
+	}
+	return nil
+}
+
+// headerContainsToken returns true if any value of the header key is a comma-separated list containing token,
+// ignoring case.
+func headerContainsToken(h http.Header, key, token string) bool {
+	for _, v := range h.Values(key) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// websocketAccept computes the Sec-WebSocket-Accept header for the provided Sec-WebSocket-Key.
+func websocketAccept(key string) string {
+	h := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
@@ -0,0 +1,103 @@
+package httpfixture_test
+
+import (
+	"bufio"
+	"github.com/orkes-io/go-httpfixture"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWebSocket(t *testing.T) {
+	s := httpfixture.NewServer(httpfixture.WebSocket("/ws", func(conn net.Conn) {
+		_, _ = io.Copy(conn, conn) // echo raw bytes; a real handler would use a WebSocket library.
+	}))
+	s.Start(t)
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", s.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("error dialing server: %v", err)
+	}
+	defer conn.Close()
+	// the sample key and accept value are from RFC 6455.
+	_, err = io.WriteString(conn, "GET /ws HTTP/1.1\r\n"+
+		"Host: localhost\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: keep-alive, Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+		"Sec-WebSocket-Version: 13\r\n\r\n")
+	if err != nil {
+		t.Fatalf("error writing handshake: %v", err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("error reading handshake response: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("want statusCode: %d; got: %d", http.StatusSwitchingProtocols, resp.StatusCode)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("want Sec-WebSocket-Accept: s3pPLMBiTxaQ9kYGzzhZRbK+xOo=; got: %s", got)
+	}
+	if _, err := io.WriteString(conn, "ping"); err != nil {
+		t.Fatalf("error writing to connection: %v", err)
+	}
+	echo := make([]byte, 4)
+	if _, err := io.ReadFull(br, echo); err != nil || string(echo) != "ping" {
+		t.Fatalf("want echo: 'ping'; got: '%s' (err: %v)", echo, err)
+	}
+}
+
+func TestWebSocketInvalidHandshake(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+	}{
+		{name: "not an upgrade", header: http.Header{}},
+		{
+			name: "missing key",
+			header: http.Header{
+				"Upgrade":               {"websocket"},
+				"Connection":            {"Upgrade"},
+				"Sec-Websocket-Version": {"13"},
+			},
+		},
+		{
+			name: "wrong version",
+			header: http.Header{
+				"Upgrade":               {"websocket"},
+				"Connection":            {"Upgrade"},
+				"Sec-Websocket-Key":     {"dGhlIHNhbXBsZSBub25jZQ=="},
+				"Sec-Websocket-Version": {"8"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockT := &testing.T{}
+			s := httpfixture.NewServer(httpfixture.WebSocket("/ws", func(conn net.Conn) {}))
+			s.Start(mockT)
+			defer s.Close()
+
+			req := must(http.NewRequest(http.MethodGet, s.URL()+"/ws", nil))
+			req.Header = tt.header
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("want statusCode: %d; got: %d", http.StatusBadRequest, resp.StatusCode)
+			}
+			if !mockT.Failed() {
+				t.Fatalf("want invalid handshake to fail the test")
+			}
+			if failures := s.AssertionFailures(); len(failures) != 1 || !strings.Contains(failures[0], "websocket") {
+				t.Fatalf("want 1 failure describing the handshake; got: %v", failures)
+			}
+		})
+	}
+}